@@ -0,0 +1,142 @@
+package ahocorasick
+
+import "github.com/ikraduya/codingchallanges/go/grep/comparisonutils"
+
+type Match struct {
+	Start        int
+	Stop         int
+	PatternIndex int
+}
+
+type node struct {
+	next map[rune]int
+	fail int
+
+	patternIndex int // index of the pattern ending here, -1 if none
+	depth        int
+
+	// closest node on the failure chain where a pattern ends, -1 if none
+	dictSuffix int
+}
+
+type Automaton struct {
+	nodes    []node
+	foldFunc comparisonutils.RuneFoldFunc
+}
+
+func newNode(depth int) node {
+	return node{next: make(map[rune]int), patternIndex: -1, depth: depth, dictSuffix: -1}
+}
+
+func Build(patterns [][]rune, foldFunc comparisonutils.RuneFoldFunc) *Automaton {
+	a := &Automaton{nodes: []node{newNode(0)}, foldFunc: foldFunc}
+
+	// trie of the patterns
+	for patternIndex, pattern := range patterns {
+		cur := 0
+		for _, r := range pattern {
+			r = foldFunc(r)
+			child, isFound := a.nodes[cur].next[r]
+			if !isFound {
+				child = len(a.nodes)
+				a.nodes = append(a.nodes, newNode(a.nodes[cur].depth+1))
+				a.nodes[cur].next[r] = child
+			}
+			cur = child
+		}
+		if a.nodes[cur].patternIndex == -1 { // duplicated patterns keep the first index
+			a.nodes[cur].patternIndex = patternIndex
+		}
+	}
+
+	// failure links, breadth first so the failure of a node is always computed before its children
+	queue := make([]int, 0, len(a.nodes))
+	for _, child := range a.nodes[0].next {
+		queue = append(queue, child)
+	}
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+
+		for r, child := range a.nodes[cur].next {
+			fail := a.nodes[cur].fail
+			for fail != 0 {
+				if _, isFound := a.nodes[fail].next[r]; isFound {
+					break
+				}
+				fail = a.nodes[fail].fail
+			}
+			if target, isFound := a.nodes[fail].next[r]; isFound && target != child {
+				a.nodes[child].fail = target
+			}
+
+			failNode := &a.nodes[a.nodes[child].fail]
+			if failNode.patternIndex != -1 {
+				a.nodes[child].dictSuffix = a.nodes[child].fail
+			} else {
+				a.nodes[child].dictSuffix = failNode.dictSuffix
+			}
+
+			queue = append(queue, child)
+		}
+	}
+
+	return a
+}
+
+func (a *Automaton) step(cur int, r rune) int {
+	for {
+		if next, isFound := a.nodes[cur].next[r]; isFound {
+			return next
+		}
+		if cur == 0 {
+			return 0
+		}
+		cur = a.nodes[cur].fail
+	}
+}
+
+// Return the leftmost-longest non-overlapping matches of every pattern in s, like grep highlights them.
+// When accept is not nil, only the matches it accepts are considered.
+func (a *Automaton) FindAll(s []rune, accept func(m Match) bool) []Match {
+	matches := make([]Match, 0)
+
+	// the leftmost-longest match so far, it is final once no match can start at or before it
+	pending := Match{Start: -1}
+	cur := 0
+	for i := 0; i < len(s) || pending.Start >= 0; {
+		if i < len(s) {
+			cur = a.step(cur, a.foldFunc(s[i]))
+			i += 1
+
+			// the patterns ending here from the longest, the first accepted one starts the leftmost
+			out := cur
+			if a.nodes[out].patternIndex == -1 {
+				out = a.nodes[out].dictSuffix
+			}
+			for ; out != -1; out = a.nodes[out].dictSuffix {
+				n := &a.nodes[out]
+				m := Match{Start: i - n.depth, Stop: i, PatternIndex: n.patternIndex}
+				if accept != nil && !accept(m) {
+					continue
+				}
+				if pending.Start < 0 || m.Start <= pending.Start { // the same start ending later is longer
+					pending = m
+				}
+				break
+			}
+
+			// a match ending later starts within the current node
+			if pending.Start < 0 || pending.Start >= i-a.nodes[cur].depth {
+				continue
+			}
+		}
+
+		// the matches seen past its stop were dropped for starting later, search again from there
+		matches = append(matches, pending)
+		i, cur = pending.Stop, 0
+		pending = Match{Start: -1}
+	}
+
+	return matches
+}
@@ -0,0 +1,120 @@
+package ahocorasick
+
+import "slices"
+
+// Automaton of byte patterns with every transition computed in advance, so that searching costs one table
+// lookup per byte of the input. The bytes that appear in no pattern share one column of the table.
+type ByteAutomaton struct {
+	classes    [256]uint16
+	classCount int
+	next       []int32 // next[state*classCount+class]
+	matchLen   []int32 // length of the longest pattern ending at a state, 0 if none
+}
+
+// Build the automaton of the non-empty patterns. With isASCIIFolded, the ASCII letters match regardless
+// of their case. With isNonASCIIMatched, every byte from 0x80 is also a match of its own, the patterns
+// must then be ASCII.
+func BuildBytes(patterns [][]byte, isASCIIFolded bool, isNonASCIIMatched bool) *ByteAutomaton {
+	a := &ByteAutomaton{}
+	if isNonASCIIMatched {
+		patterns = append(slices.Clip(patterns), []byte{0x80}) // stands for the bytes from 0x80, that share its class
+	}
+
+	fold := func(b byte) byte {
+		if isASCIIFolded && b >= 'A' && b <= 'Z' {
+			return b + 'a' - 'A'
+		}
+		return b
+	}
+
+	// one class per folded byte of the patterns, class 0 for the other bytes
+	var folded [256]uint16
+	for _, pattern := range patterns {
+		for _, b := range pattern {
+			b = fold(b)
+			if folded[b] == 0 {
+				a.classCount += 1
+				folded[b] = uint16(a.classCount)
+			}
+		}
+	}
+	a.classCount += 1
+	for b := range a.classes {
+		a.classes[b] = folded[fold(byte(b))]
+		if isNonASCIIMatched && b >= 0x80 {
+			a.classes[b] = folded[0x80]
+		}
+	}
+
+	// trie of the patterns, -1 for a missing child
+	addState := func() int32 {
+		for i := 0; i < a.classCount; i++ {
+			a.next = append(a.next, -1)
+		}
+		a.matchLen = append(a.matchLen, 0)
+		return int32(len(a.matchLen) - 1)
+	}
+	addState()
+	for _, pattern := range patterns {
+		cur := int32(0)
+		for _, b := range pattern {
+			i := int(cur)*a.classCount + int(a.classes[b])
+			if a.next[i] == -1 {
+				child := addState()
+				a.next[i] = child
+			}
+			cur = a.next[i]
+		}
+		if len(pattern) > 0 {
+			a.matchLen[cur] = int32(len(pattern))
+		}
+	}
+
+	// breadth first, the missing children of a state are the children of its failure state
+	fail := make([]int32, len(a.matchLen))
+	queue := make([]int32, 0, len(a.matchLen))
+	for class := 0; class < a.classCount; class++ {
+		if child := a.next[class]; child == -1 {
+			a.next[class] = 0
+		} else {
+			queue = append(queue, child)
+		}
+	}
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+		if a.matchLen[cur] == 0 {
+			a.matchLen[cur] = a.matchLen[fail[cur]]
+		}
+
+		for class := 0; class < a.classCount; class++ {
+			i := int(cur)*a.classCount + class
+			failNext := a.next[int(fail[cur])*a.classCount+class]
+			if child := a.next[i]; child == -1 {
+				a.next[i] = failNext
+			} else {
+				fail[child] = failNext
+				queue = append(queue, child)
+			}
+		}
+	}
+
+	return a
+}
+
+// Return the start of the pattern that ends first in data, the longest one when several end there, or -1
+func (a *ByteAutomaton) Index(data []byte) int {
+	if len(a.matchLen) == 1 {
+		return -1
+	}
+
+	cur := int32(0)
+	for i, b := range data {
+		cur = a.next[int(cur)*a.classCount+int(a.classes[b])]
+		if n := a.matchLen[cur]; n > 0 {
+			return i + 1 - int(n)
+		}
+	}
+
+	return -1
+}
@@ -0,0 +1,20 @@
+#!/bin/bash
+# Time ccgrep on a generated corpus, BASELINE=path/to/ccgrep also times another build to compare with
+# Usage: ./bench.sh [REPEAT]
+
+REPEAT=${1:-480}
+CORPUS=$(mktemp)
+trap 'rm -f "$CORPUS"' EXIT
+
+for i in $(seq 1 "$REPEAT"); do
+	cat *.go search/*.go internal/*/*.go rockbands.txt test-subdir/*
+done > "$CORPUS"
+echo "corpus: $(wc -c < "$CORPUS") bytes"
+
+TIMEFORMAT="%R s"
+for args in "-c Nirvana" "-c -F -e Nirvana -e Kiss" "-c return\ nil" "-c func.*error" "-c -v Nirvana" "-n Springsteen" "-c [0-9][0-9][0-9]" "-c -i nirvana"; do
+	for exe in ./ccgrep $BASELINE; do
+		printf '%-40s %-20s ' "$args" "$exe"
+		eval time "$exe" "$args" "$CORPUS" > /dev/null
+	done
+done
@@ -0,0 +1,631 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/ikraduya/codingchallanges/go/grep/search"
+)
+
+// The options and the operands of the command line
+type Args struct {
+	Expressions  []string
+	PatternFiles []string
+	Filepaths    []string
+
+	IsRecurse          bool
+	IsInvertExpression bool
+	IsCaseInsensitive  bool
+	IsSmartCase        bool
+
+	IsBasicRegexp    bool
+	IsExtendedRegexp bool
+	IsFixedStrings   bool
+	IsPerlRegexp     bool
+
+	IsLineNumberShown bool
+	IsByteOffsetShown bool
+	IsColumnShown     bool
+	IsVimgrep         bool
+	IsJSON            bool
+
+	ReplaceTemplate string
+	IsReplaced      bool
+	InPlace         inPlaceFlag
+	IsDryRun        bool
+
+	IsMultiline       bool
+	IsMultilineDotAll bool
+
+	IsNullData     bool
+	IsNullFilepath bool
+
+	IsZipSearched bool
+
+	AfterContext  int
+	BeforeContext int
+	Context       int
+
+	IsCountMode             bool
+	IsFilesWithMatchesMode  bool
+	IsFilesWithoutMatchMode bool
+	IsQuietMode             bool
+	MaxCount                int
+
+	IsOnlyMatching bool
+	IsWordMatch    bool
+	IsLineMatch    bool
+	FuzzyDistance  int
+	Normalize      string
+
+	ColorMode search.ColorMode
+
+	JobCount    int
+	IsUnordered bool
+
+	MaxLineLength int
+
+	BinaryFiles  string
+	IsBinaryText bool
+	IsBinarySkip bool
+
+	Includes         []string
+	Excludes         []string
+	ExcludeDirs      []string
+	MaxDepth         int
+	IsHiddenShown    bool
+	IsIgnoreDisabled bool
+
+	IsArchiveSearched bool
+	MaxArchiveDepth   int
+
+	IsIndexed bool
+
+	ExeName        string
+	UseStdInStream bool
+}
+
+// Flag that can be repeated, e.g. "-e foo -e bar"
+type stringListFlag []string
+
+func (list *stringListFlag) String() string {
+	return strings.Join(*list, ",")
+}
+
+func (list *stringListFlag) Set(value string) error {
+	*list = append(*list, value)
+	return nil
+}
+
+// Value of --color, a bare "--color" is "auto" like in GNU grep
+type colorModeFlag search.ColorMode
+
+func (mode *colorModeFlag) String() string {
+	return ""
+}
+
+func (mode *colorModeFlag) Set(value string) error {
+	if value == "true" { // set by the flag package for a bare "--color"
+		value = "auto"
+	}
+
+	colorMode, err := search.ParseColorMode(value)
+	if err != nil {
+		return err
+	}
+	*mode = colorModeFlag(colorMode)
+	return nil
+}
+
+func (mode *colorModeFlag) IsBoolFlag() bool {
+	return true
+}
+
+// Value of --in-place, a bare "--in-place" makes no backup
+type inPlaceFlag struct {
+	isSet  bool
+	suffix string
+}
+
+func (flag *inPlaceFlag) String() string {
+	return flag.suffix
+}
+
+func (flag *inPlaceFlag) Set(value string) error {
+	flag.isSet = true
+	if value != "true" { // set by the flag package for a bare "--in-place"
+		flag.suffix = value
+	}
+	return nil
+}
+
+func (flag *inPlaceFlag) IsBoolFlag() bool {
+	return true
+}
+
+func printHelp(exeName string) {
+	fmt.Printf("Usage: %s [OPTION]... EXPRESSION [FILE]...\n", exeName)
+	fmt.Printf("  or:  %s [OPTION]... -e EXPRESSION... [-f FILE]... [FILE]...\n", exeName)
+	fmt.Printf("  or:  %s index build [--hidden] [--no-ignore] [DIR]\n", exeName)
+	fmt.Println("OPTION: the short options can be combined like in '-inA2', the options end at EXPRESSION")
+	fmt.Println("\t'-r' Recurse the directory tree")
+	fmt.Println("\t'-v' Inverse the match expression")
+	fmt.Println("\t'-i' Case insensitive match, with the full Unicode case folding, e.g. 'ß' matches 'SS' and 'ς' matches 'Σ'")
+	fmt.Println("\t'-S', '--smart-case' Match an expression case insensitively when it has no upper case letter, the escapes like '\\W' and the classes like '[[:upper:]]' do not count")
+	fmt.Println("\t'-G' EXPRESSION is a basic regular expression (default)")
+	fmt.Println("\t'-E' EXPRESSION is an extended regular expression")
+	fmt.Println("\t'-F' EXPRESSION is a fixed string")
+	fmt.Println("\t'-P' EXPRESSION is a Perl regular expression")
+	fmt.Println("\t'-e EXPRESSION' Use EXPRESSION for matching, can be repeated")
+	fmt.Println("\t'-f FILE' Take the expressions from FILE, one per line, can be repeated")
+	fmt.Println("\t'-n' Print the line number of each line")
+	fmt.Println("\t'-b' Print the byte offset of each line")
+	fmt.Println("\t'--column' Print the column of the first match of each line")
+	fmt.Println("\t'--vimgrep' Print every match as FILE:LINE:COLUMN:TEXT")
+	fmt.Println("\t'--json' Print one JSON event per line: begin, match, context and end of each file, then a summary")
+	fmt.Println("\t'--replace TEMPLATE' Print the lines with every match replaced, '$1' or '${1}' is a group, '${name}' a named group and '$$' a dollar sign")
+	fmt.Println("\t'--in-place[=SUFFIX]' Rewrite the files with the replacements, keep the originals as FILE+SUFFIX when given, the compressed files and the archives are left as is")
+	fmt.Println("\t'--dry-run' Print a unified diff of what --in-place would change")
+	fmt.Println("\t'-U', '--multiline' Match the expressions against the whole input, print every line a match touches")
+	fmt.Println("\t'--multiline-dotall' Let '.' match a newline with -U")
+	fmt.Println("\t'-z' Input and output lines are terminated by NUL instead of a newline")
+	fmt.Println("\t'-Z', '--null' Print a NUL after each file name instead of ':' or a newline")
+	fmt.Println("\t'--search-zip' Search the content of gzip, bzip2 and zlib compressed files, detected by their first bytes")
+	fmt.Println("\t'-A NUM' Print NUM lines of context after each match")
+	fmt.Println("\t'-B NUM' Print NUM lines of context before each match")
+	fmt.Println("\t'-C NUM' Print NUM lines of context around each match")
+	fmt.Println("\t'-c' Print only the count of selected lines per file")
+	fmt.Println("\t'-l' Print only the names of files with selected lines")
+	fmt.Println("\t'-L' Print only the names of files without selected lines")
+	fmt.Println("\t'-q' Print nothing, exit with zero status on the first selected line")
+	fmt.Println("\t'-m NUM' Stop reading a file after NUM selected lines")
+	fmt.Println("\t'-o' Print only the matched parts of a line, one per line")
+	fmt.Println("\t'-w' Match only whole words")
+	fmt.Println("\t'-x' Match only whole lines")
+	fmt.Println("\t'--fuzzy NUM' Match the expressions as fixed strings within NUM edits (insertion, deletion or substitution of a character)")
+	fmt.Println("\t'--normalize=FORM' Match the lines and the expressions in the Unicode normalization FORM, 'nfc', 'nfd', 'nfkc' or 'nfkd'")
+	fmt.Println("\t'--color[=WHEN]' Color the output, WHEN is 'auto' (default), 'always' or 'never'")
+	fmt.Println("\t'-j NUM' Search NUM files in parallel (default: the number of CPUs)")
+	fmt.Println("\t'--unordered' Print the files as soon as they are searched instead of in walk order")
+	fmt.Printf("\t'--max-line-length NUM' Stop searching a file with an error at a line longer than NUM bytes, a line is held whole in memory, 0 or -1 for no limit (default: %d)\n", search.DefaultMaxLineLength)
+	fmt.Println("\t'-a' Search binary files as if they were text")
+	fmt.Println("\t'-I' Skip binary files, as if they did not match")
+	fmt.Println("\t'--binary-files=TYPE' How binary files are searched, TYPE is 'binary' (default), 'text' or 'without-match'")
+	fmt.Println("\t'--include GLOB' Search only the files whose name matches GLOB, can be repeated")
+	fmt.Println("\t'--exclude GLOB' Skip the files whose name matches GLOB, can be repeated")
+	fmt.Println("\t'--exclude-dir GLOB' Skip the directories whose name matches GLOB when recursing, can be repeated")
+	fmt.Println("\t'--max-depth NUM' Descend at most NUM levels below the directories when recursing")
+	fmt.Println("\t'--hidden' Search the hidden files and directories when recursing")
+	fmt.Println("\t'--no-ignore' Do not respect .gitignore, .ignore and the git excludes when recursing")
+	fmt.Println("\t'--search-archives' Search the members of .zip, .tar, .tar.gz and .tgz archives, printed as ARCHIVE:MEMBER, --include, --exclude, --exclude-dir and --hidden apply to the members")
+	fmt.Println("\t'--archive-depth NUM' Open the archives nested in at most NUM levels (default: 2), the deeper ones are searched as files")
+	fmt.Println("\t'--index' With -r, skip the files that the index of a directory rules out, the files changed since it was built are searched")
+}
+
+// Parse the command line, the error is a usage error or flag.ErrHelp
+func (args *Args) Parse() error {
+	args.ExeName = filepath.Base(os.Args[0])
+
+	fs := flag.NewFlagSet(args.ExeName, flag.ContinueOnError)
+	fs.SetOutput(io.Discard) // prevent flag from printing parse error
+
+	fs.BoolVar(&args.IsRecurse, "r", false, "recurse the directory tree")
+	fs.BoolVar(&args.IsInvertExpression, "v", false, "invert the match expression")
+	fs.BoolVar(&args.IsCaseInsensitive, "i", false, "case insensitive match")
+	fs.BoolVar(&args.IsSmartCase, "S", false, "case insensitive match unless the expression has an upper case letter")
+	fs.BoolVar(&args.IsSmartCase, "smart-case", false, "case insensitive match unless the expression has an upper case letter")
+	fs.BoolVar(&args.IsBasicRegexp, "G", false, "basic regular expression")
+	fs.BoolVar(&args.IsExtendedRegexp, "E", false, "extended regular expression")
+	fs.BoolVar(&args.IsFixedStrings, "F", false, "fixed string")
+	fs.BoolVar(&args.IsPerlRegexp, "P", false, "Perl regular expression")
+	fs.Var((*stringListFlag)(&args.Expressions), "e", "expression for matching")
+	fs.Var((*stringListFlag)(&args.PatternFiles), "f", "file of expressions")
+	fs.BoolVar(&args.IsLineNumberShown, "n", false, "print line number")
+	fs.BoolVar(&args.IsByteOffsetShown, "b", false, "print byte offset")
+	fs.BoolVar(&args.IsColumnShown, "column", false, "print column of the first match")
+	fs.BoolVar(&args.IsVimgrep, "vimgrep", false, "print every match as FILE:LINE:COLUMN:TEXT")
+	fs.BoolVar(&args.IsJSON, "json", false, "print the results as JSON Lines")
+	fs.StringVar(&args.ReplaceTemplate, "replace", "", "replace every match with TEMPLATE")
+	fs.Var(&args.InPlace, "in-place", "rewrite the files with the replacements")
+	fs.BoolVar(&args.IsDryRun, "dry-run", false, "print the diff of --in-place without rewriting")
+	fs.BoolVar(&args.IsMultiline, "U", false, "match the expressions across lines")
+	fs.BoolVar(&args.IsMultiline, "multiline", false, "match the expressions across lines")
+	fs.BoolVar(&args.IsMultilineDotAll, "multiline-dotall", false, "'.' also matches a newline with -U")
+	fs.BoolVar(&args.IsNullData, "z", false, "lines are terminated by NUL")
+	fs.BoolVar(&args.IsNullFilepath, "Z", false, "print NUL after each file name")
+	fs.BoolVar(&args.IsNullFilepath, "null", false, "print NUL after each file name")
+	fs.BoolVar(&args.IsZipSearched, "search-zip", false, "search in compressed files")
+	fs.IntVar(&args.AfterContext, "A", -1, "lines of context after each match")
+	fs.IntVar(&args.BeforeContext, "B", -1, "lines of context before each match")
+	fs.IntVar(&args.Context, "C", 0, "lines of context around each match")
+	fs.BoolVar(&args.IsCountMode, "c", false, "print only the count of selected lines")
+	fs.BoolVar(&args.IsFilesWithMatchesMode, "l", false, "print only the names of files with selected lines")
+	fs.BoolVar(&args.IsFilesWithoutMatchMode, "L", false, "print only the names of files without selected lines")
+	fs.BoolVar(&args.IsQuietMode, "q", false, "print nothing")
+	fs.IntVar(&args.MaxCount, "m", -1, "stop reading a file after NUM selected lines")
+	fs.BoolVar(&args.IsOnlyMatching, "o", false, "print only the matched parts of a line")
+	fs.BoolVar(&args.IsWordMatch, "w", false, "match only whole words")
+	fs.BoolVar(&args.IsLineMatch, "x", false, "match only whole lines")
+	fs.IntVar(&args.FuzzyDistance, "fuzzy", -1, "match within NUM edits")
+	fs.StringVar(&args.Normalize, "normalize", "", "Unicode normalization form of the matching")
+	fs.Var((*colorModeFlag)(&args.ColorMode), "color", "color the output")
+	fs.Var((*colorModeFlag)(&args.ColorMode), "colour", "color the output")
+	fs.IntVar(&args.JobCount, "j", runtime.GOMAXPROCS(0), "number of files searched in parallel")
+	fs.BoolVar(&args.IsUnordered, "unordered", false, "print the files as soon as they are searched")
+	fs.IntVar(&args.MaxLineLength, "max-line-length", search.DefaultMaxLineLength, "longest line held in memory in bytes")
+	fs.BoolVar(&args.IsBinaryText, "a", false, "search binary files as text")
+	fs.BoolVar(&args.IsBinarySkip, "I", false, "skip binary files")
+	fs.StringVar(&args.BinaryFiles, "binary-files", "binary", "how binary files are searched")
+	fs.Var((*stringListFlag)(&args.Includes), "include", "glob of the files to search")
+	fs.Var((*stringListFlag)(&args.Excludes), "exclude", "glob of the files to skip")
+	fs.Var((*stringListFlag)(&args.ExcludeDirs), "exclude-dir", "glob of the directories to skip")
+	fs.IntVar(&args.MaxDepth, "max-depth", -1, "deepest level to descend when recursing")
+	fs.BoolVar(&args.IsHiddenShown, "hidden", false, "search hidden files and directories")
+	fs.BoolVar(&args.IsIgnoreDisabled, "no-ignore", false, "do not respect ignore files")
+	fs.BoolVar(&args.IsArchiveSearched, "search-archives", false, "search the members of zip and tar archives")
+	fs.IntVar(&args.MaxArchiveDepth, "archive-depth", 2, "deepest nesting level of an opened archive")
+	fs.BoolVar(&args.IsIndexed, "index", false, "skip the files the trigram index rules out")
+
+	if err := fs.Parse(expandShortOptions(fs, os.Args[1:])); err != nil {
+		return err
+	}
+
+	syntaxFlagCount := 0
+	for _, isSet := range []bool{args.IsBasicRegexp, args.IsExtendedRegexp, args.IsFixedStrings, args.IsPerlRegexp} {
+		if isSet {
+			syntaxFlagCount += 1
+		}
+	}
+	if syntaxFlagCount > 1 {
+		return errors.New("conflicting matchers specified")
+	}
+
+	if args.IsJSON && (args.IsCountMode || args.IsFilesWithMatchesMode || args.IsFilesWithoutMatchMode || args.IsQuietMode) {
+		return errors.New("--json cannot be used with -c, -l, -L or -q")
+	}
+
+	if args.JobCount < 1 {
+		return errors.New("NUM of jobs must be positive")
+	}
+	if _, err := ParseBinaryFilesMode(args.BinaryFiles); err != nil {
+		return err
+	}
+	if args.Normalize != "" {
+		if _, err := search.ParseNormalization(args.Normalize); err != nil {
+			return err
+		}
+	}
+	if args.MaxLineLength < -1 {
+		return errors.New("NUM of --max-line-length must be positive, or 0 or -1 for no limit")
+	}
+	if args.MaxLineLength == 0 {
+		args.MaxLineLength = -1 // like -1, no limit
+	}
+	if args.MaxArchiveDepth < 1 {
+		return errors.New("NUM of --archive-depth must be positive")
+	}
+	if args.MaxDepth < -1 {
+		args.MaxDepth = -1
+	}
+	if args.MaxCount < -1 {
+		args.MaxCount = -1 // like GNU grep, a negative NUM is no limit
+	}
+	if args.Context < 0 || args.AfterContext < -1 || args.BeforeContext < -1 {
+		return errors.New("NUM of a context must not be negative")
+	}
+	// -A and -B take precedence over -C
+	if args.AfterContext == -1 {
+		args.AfterContext = args.Context
+	}
+	if args.BeforeContext == -1 {
+		args.BeforeContext = args.Context
+	}
+
+	positionals := fs.Args()
+	if len(args.Expressions) == 0 && len(args.PatternFiles) == 0 { // the expression is the first positional
+		if len(positionals) == 0 {
+			return errors.New("EXPRESSION is required")
+		}
+		args.Expressions = append(args.Expressions, positionals[0])
+		positionals = positionals[1:]
+	}
+
+	args.Filepaths = positionals
+	args.UseStdInStream = len(args.Filepaths) == 0
+
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == "replace" { // an empty TEMPLATE deletes the matches
+			args.IsReplaced = true
+		}
+	})
+	if args.FuzzyDistance < -1 {
+		return errors.New("NUM of --fuzzy must not be negative")
+	}
+	if args.FuzzyDistance >= 0 && (args.IsMultiline || args.IsReplaced) {
+		return errors.New("--fuzzy cannot be used with -U or --replace")
+	}
+	if args.IsMultiline && args.IsReplaced {
+		return errors.New("--replace cannot be used with -U")
+	}
+	if args.InPlace.isSet || args.IsDryRun {
+		message := ""
+		switch {
+		case !args.IsReplaced:
+			message = "--in-place and --dry-run require --replace"
+		case args.UseStdInStream:
+			message = "--in-place and --dry-run require FILE arguments"
+		case args.IsInvertExpression:
+			message = "--in-place and --dry-run cannot be used with -v"
+		case args.IsZipSearched || args.IsArchiveSearched:
+			message = "--in-place and --dry-run cannot be used with --search-zip or --search-archives"
+		}
+		if message != "" {
+			return errors.New(message)
+		}
+		args.InPlace.isSet = true // a bare --dry-run previews the rewrite
+	}
+
+	if args.IsOnlyMatching { // there is no context around a part of a line
+		args.AfterContext = 0
+		args.BeforeContext = 0
+	}
+
+	return nil
+}
+
+// Split the short options like GNU grep does: "-nA2" is "-n -A 2" and "-m1" is "-m 1". The flag package
+// only takes one option per argument, the arguments that name a flag like "-color" are left as they are.
+func expandShortOptions(fs *flag.FlagSet, arguments []string) []string {
+	takesValue := func(f *flag.Flag) bool {
+		boolFlag, ok := f.Value.(interface{ IsBoolFlag() bool })
+		return !ok || !boolFlag.IsBoolFlag()
+	}
+
+	expanded := make([]string, 0, len(arguments))
+	for i := 0; i < len(arguments); i++ {
+		arg := arguments[i]
+		if arg == "--" || arg == "-" || !strings.HasPrefix(arg, "-") { // the options end at the first operand
+			return append(expanded, arguments[i:]...)
+		}
+
+		name, _, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if f := fs.Lookup(name); f != nil || strings.HasPrefix(arg, "--") {
+			expanded = append(expanded, arg)
+			if f != nil && !hasValue && takesValue(f) && i+1 < len(arguments) { // "-e -v" searches for "-v"
+				i += 1
+				expanded = append(expanded, arguments[i])
+			}
+			continue
+		}
+
+		options := make([]string, 0, len(arg)-1)
+		for j := 1; j < len(arg); j++ {
+			f := fs.Lookup(arg[j : j+1])
+			if f == nil { // left as is for the error of the flag package
+				options = []string{arg}
+				break
+			}
+			options = append(options, "-"+f.Name)
+			if takesValue(f) {
+				if value := arg[j+1:]; value != "" {
+					options = append(options, value)
+				} else if i+1 < len(arguments) { // "-nA 2"
+					i += 1
+					options = append(options, arguments[i])
+				}
+				break
+			}
+		}
+		expanded = append(expanded, options...)
+	}
+
+	return expanded
+}
+
+// -q takes precedence over -l, -L and -c
+func (args *Args) OutputMode() search.OutputMode {
+	switch {
+	case args.IsQuietMode:
+		return search.OutputQuiet
+	case args.IsFilesWithMatchesMode:
+		return search.OutputFilesWithMatches
+	case args.IsFilesWithoutMatchMode:
+		return search.OutputFilesWithoutMatch
+	case args.IsCountMode:
+		return search.OutputCount
+	default:
+		return search.OutputLines
+	}
+}
+
+// Parse the TYPE of --binary-files: "binary", "text" or "without-match"
+func ParseBinaryFilesMode(value string) (search.BinaryFilesMode, error) {
+	switch value {
+	case "binary":
+		return search.BinaryFilesBinary, nil
+	case "text":
+		return search.BinaryFilesText, nil
+	case "without-match":
+		return search.BinaryFilesWithoutMatch, nil
+	}
+
+	return search.BinaryFilesBinary, fmt.Errorf("invalid argument %q for --binary-files", value)
+}
+
+// -a and -I take precedence over --binary-files
+func (args *Args) BinaryFilesMode() search.BinaryFilesMode {
+	switch {
+	case args.IsBinaryText:
+		return search.BinaryFilesText
+	case args.IsBinarySkip:
+		return search.BinaryFilesWithoutMatch
+	}
+
+	mode, _ := ParseBinaryFilesMode(args.BinaryFiles) // validated by Parse
+	return mode
+}
+
+// Which files of the FILEs are searched
+func (args *Args) WalkOption() search.WalkOption {
+	return search.WalkOption{
+		IsRecurse:        args.IsRecurse,
+		Includes:         args.Includes,
+		Excludes:         args.Excludes,
+		ExcludeDirs:      args.ExcludeDirs,
+		MaxDepth:         args.MaxDepth,
+		IsHiddenShown:    args.IsHiddenShown,
+		IsIgnoreDisabled: args.IsIgnoreDisabled,
+
+		IsArchiveSearched: args.IsArchiveSearched,
+		MaxArchiveDepth:   args.MaxArchiveDepth,
+	}
+}
+
+// -F, -E and -P take precedence in this order, the default is -G
+func (args *Args) RegexSyntax() search.Syntax {
+	switch {
+	case args.IsFixedStrings:
+		return search.SyntaxFixed
+	case args.IsExtendedRegexp:
+		return search.SyntaxExtended
+	case args.IsPerlRegexp:
+		return search.SyntaxPerl
+	default:
+		return search.SyntaxBasic
+	}
+}
+
+// The search of the expressions, the printer only has the lines it prints delivered
+func (args *Args) SearchOption(expressions []string) search.Option {
+	option := search.NewOption(expressions...)
+	option.Syntax = args.RegexSyntax()
+	switch {
+	case args.IsCaseInsensitive: // -i wins over -S
+		option.CaseMode = search.CaseInsensitive
+	case args.IsSmartCase:
+		option.CaseMode = search.SmartCase
+	}
+	option.IsInvertExpression = args.IsInvertExpression
+	option.IsWordMatch = args.IsWordMatch
+	option.IsLineMatch = args.IsLineMatch
+	if args.Normalize != "" {
+		option.Normalization, _ = search.ParseNormalization(args.Normalize) // validated by Parse
+	}
+	option.IsFuzzy = args.FuzzyDistance >= 0
+	option.FuzzyDistance = args.FuzzyDistance
+	option.IsMultiline = args.IsMultiline
+	option.IsMultilineDotAll = args.IsMultilineDotAll
+	option.IsReplaced = args.IsReplaced
+	option.Replacement = args.ReplaceTemplate
+	option.MaxLineLength = args.MaxLineLength
+
+	option.BeforeContext = args.BeforeContext
+	option.AfterContext = args.AfterContext
+	option.MaxCount = args.MaxCount
+	option.IsNullData = args.IsNullData
+	option.BinaryFiles = args.BinaryFilesMode()
+	option.IsZipSearched = args.IsZipSearched
+
+	option.Walk = args.WalkOption()
+	option.IsIndexed = args.IsIndexed
+	option.JobCount = args.JobCount
+	option.IsUnordered = args.IsUnordered
+
+	return option
+}
+
+// How the printer prints the inputs
+func (args *Args) PrintOption() search.PrintOption {
+	printOption := search.PrintOption{
+		OutputMode:          args.OutputMode(),
+		IsLineNumberShown:   args.IsLineNumberShown,
+		IsByteOffsetShown:   args.IsByteOffsetShown,
+		IsColumnShown:       args.IsColumnShown,
+		IsNullAfterFilepath: args.IsNullFilepath,
+		IsVimgrep:           args.IsVimgrep,
+		IsOnlyMatching:      args.IsOnlyMatching,
+		IsJSON:              args.IsJSON,
+		ColorMode:           args.ColorMode,
+		ExeName:             args.ExeName,
+	}
+	if args.InPlace.isSet {
+		printOption.InPlace = &search.EditOption{BackupSuffix: args.InPlace.suffix, IsDryRun: args.IsDryRun}
+	}
+
+	return printOption
+}
+
+// Collect every expression, an expression with newlines is one expression per line like in a pattern file
+func (args *Args) LoadExpressions() ([]string, error) {
+	expressions := make([]string, 0, len(args.Expressions))
+	for _, expression := range args.Expressions {
+		expressions = append(expressions, strings.Split(expression, "\n")...)
+	}
+
+	for _, patternFile := range args.PatternFiles {
+		var content []byte
+		var err error
+		if patternFile == "-" {
+			content, err = io.ReadAll(os.Stdin)
+		} else {
+			content, err = os.ReadFile(patternFile)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if len(content) == 0 { // an empty file has no expression, not an empty one
+			continue
+		}
+		lines := strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+		for _, line := range lines {
+			expressions = append(expressions, strings.TrimSuffix(line, "\r"))
+		}
+	}
+
+	return expressions, nil
+}
+
+func main() {
+	// "ccgrep index FILE" still searches for "index", only "index build" is the subcommand
+	if len(os.Args) > 2 && os.Args[1] == "index" && os.Args[2] == "build" {
+		os.Exit(runIndexBuild(filepath.Base(os.Args[0]), os.Args[3:]))
+	}
+
+	var args Args
+	if err := args.Parse(); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			printHelp(args.ExeName)
+			os.Exit(0)
+		}
+		fmt.Fprintf(os.Stderr, "%s: %v\n", args.ExeName, err)
+		fmt.Fprintf(os.Stderr, "Usage: %s [OPTION]... EXPRESSION [FILE]...\n", args.ExeName)
+		fmt.Fprintf(os.Stderr, "Try '%s --help' for more information.\n", args.ExeName)
+		os.Exit(2)
+	}
+
+	expressions, err := args.LoadExpressions()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", args.ExeName, err)
+		os.Exit(2)
+	}
+
+	printer, err := search.NewPrinter(os.Stdout, os.Stderr, args.SearchOption(expressions), args.PrintOption())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", args.ExeName, err)
+		os.Exit(2)
+	}
+
+	if args.UseStdInStream {
+		printer.PrintReader(os.Stdin, search.StdinName)
+	} else { // grep all files in args.Filepaths
+		printer.PrintTree(args.Filepaths)
+	}
+	os.Exit(printer.Finish())
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/ikraduya/codingchallanges/go/grep/search"
+)
+
+func printIndexHelp(exeName string) {
+	fmt.Printf("Usage: %s index build [OPTION]... [DIR]\n", exeName)
+	fmt.Printf("Write the trigram index of the files of DIR (default: '.') into DIR/%s, used by --index\n", search.IndexFilename)
+	fmt.Println("Only the files that are new or changed since the previous build are read again.")
+	fmt.Println("OPTION:")
+	fmt.Println("\t'--hidden' Index the hidden files and directories")
+	fmt.Println("\t'--no-ignore' Do not respect .gitignore, .ignore and the git excludes")
+}
+
+// Run "ccgrep index build ARGUMENTS...", return the exit status
+func runIndexBuild(exeName string, arguments []string) int {
+	fs := flag.NewFlagSet(exeName, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	var walkOption search.WalkOption
+	fs.BoolVar(&walkOption.IsHiddenShown, "hidden", false, "index hidden files and directories")
+	fs.BoolVar(&walkOption.IsIgnoreDisabled, "no-ignore", false, "do not respect ignore files")
+	err := fs.Parse(arguments)
+	if errors.Is(err, flag.ErrHelp) {
+		printIndexHelp(exeName)
+		return 0
+	}
+	if err == nil && fs.NArg() > 1 {
+		err = errors.New("only one DIR can be indexed")
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", exeName, err)
+		fmt.Fprintf(os.Stderr, "Try '%s index build --help' for more information.\n", exeName)
+		return 2
+	}
+
+	dir := "."
+	if fs.NArg() == 1 {
+		dir = fs.Arg(0)
+	}
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		if err == nil {
+			err = errors.New("is not a directory")
+		}
+		fmt.Fprintf(os.Stderr, "%s: %s: %s\n", exeName, dir, search.ErrorText(err))
+		return 2
+	}
+
+	hasError := false
+	stats, err := search.BuildIndex(dir, walkOption, func(path string, err error) {
+		var indexErr *search.IndexError
+		switch {
+		case errors.As(err, &indexErr):
+			fmt.Fprintf(os.Stderr, "%s: %v, building it again\n", exeName, indexErr.Err)
+		case errors.Is(err, search.ErrIsDirectory) || errors.Is(err, search.ErrNotRegular):
+			fmt.Printf("%s: %s: %s\n", exeName, path, search.ErrorText(err))
+		default:
+			fmt.Fprintf(os.Stderr, "%s: %s: %s\n", exeName, path, search.ErrorText(err))
+			hasError = true
+		}
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", exeName, err)
+		return 2
+	}
+
+	fmt.Printf("%s: %d files, %d read, %d removed\n", stats.Path, stats.FileCount, stats.ReadCount, stats.RemovedCount)
+	if hasError {
+		return 2
+	}
+	return 0
+}
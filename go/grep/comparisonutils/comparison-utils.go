@@ -4,9 +4,46 @@ import "unicode"
 
 type RuneComparisonFunc func(a rune, b rune) bool
 
+// Map a rune to the canonical form used as a lookup key, equal keys mean equal runes
+type RuneFoldFunc func(r rune) rune
+
 func AreRunesCaseSensitiveEqual(a rune, b rune) bool {
 	return a == b
 }
 func AreRunesCaseInsensitiveEqual(a rune, b rune) bool {
-	return unicode.ToLower(a) == unicode.ToLower(b)
+	return FoldRuneCaseInsensitive(a) == FoldRuneCaseInsensitive(b)
+}
+
+func FoldRuneCaseSensitive(r rune) rune {
+	return r
+}
+
+// ASCII is folded by a lookup, small enough to be inlined
+var asciiFolds [0x80]rune
+
+func init() {
+	for r := range asciiFolds {
+		asciiFolds[r] = unicode.ToLower(rune(r))
+	}
+}
+
+// Simple case folding of CaseFolding.txt: the runes of an orbit of unicode.SimpleFold map to one of them,
+// e.g. 'Σ', 'σ' and 'ς' map to 'σ', 'K', 'k' and 'K' (Kelvin sign) to 'k'
+func FoldRuneCaseInsensitive(r rune) rune {
+	if r < 0x80 {
+		return asciiFolds[r]
+	}
+	return foldNonASCII(r)
+}
+
+func foldNonASCII(r rune) rune {
+	if unicode.SimpleFold(r) == r { // e.g. 'İ', that only folds to several runes
+		return r
+	}
+
+	upper := unicode.ToUpper(r)
+	if upper >= 0x13A0 && upper <= 0x13F5 { // Cherokee folds to the upper case
+		return upper
+	}
+	return unicode.ToLower(upper)
 }
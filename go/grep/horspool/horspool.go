@@ -0,0 +1,71 @@
+package horspool
+
+import "github.com/ikraduya/codingchallanges/go/grep/comparisonutils"
+
+// Boyer-Moore-Horspool search of one pattern. The text is compared from the end of the window and the
+// window is shifted by the distance of its last rune to its last occurrence in the pattern, so long
+// patterns over a large alphabet skip most of the text.
+type Searcher struct {
+	pattern        []rune
+	comparisonFunc comparisonutils.RuneComparisonFunc
+	foldFunc       comparisonutils.RuneFoldFunc
+
+	asciiShifts [128]int
+	shifts      map[rune]int // shifts of the folded runes outside ASCII
+}
+
+func NewSearcher(pattern []rune, comparisonFunc comparisonutils.RuneComparisonFunc, foldFunc comparisonutils.RuneFoldFunc) *Searcher {
+	searcher := &Searcher{pattern: pattern, comparisonFunc: comparisonFunc, foldFunc: foldFunc, shifts: make(map[rune]int)}
+
+	patLen := len(pattern)
+	for i := range searcher.asciiShifts {
+		searcher.asciiShifts[i] = patLen
+	}
+	// the last rune is left out, a window ending with it must still move
+	for i := 0; i < patLen-1; i++ {
+		r := foldFunc(pattern[i])
+		if r >= 0 && r < 128 {
+			searcher.asciiShifts[r] = patLen - 1 - i
+		} else {
+			searcher.shifts[r] = patLen - 1 - i
+		}
+	}
+
+	return searcher
+}
+
+func (searcher *Searcher) shift(r rune) int {
+	r = searcher.foldFunc(r)
+	if r >= 0 && r < 128 {
+		return searcher.asciiShifts[r]
+	}
+	if shift, isFound := searcher.shifts[r]; isFound {
+		return shift
+	}
+
+	return len(searcher.pattern)
+}
+
+// Return the index of the first occurrence of the pattern in s at or after start, or -1
+func (searcher *Searcher) Index(s []rune, start int) int {
+	patLen := len(searcher.pattern)
+	if patLen == 0 {
+		return start
+	}
+
+	for j := start; j <= len(s)-patLen; {
+		last := s[j+patLen-1]
+
+		i := patLen - 1
+		for i >= 0 && searcher.comparisonFunc(s[j+i], searcher.pattern[i]) {
+			i -= 1
+		}
+		if i < 0 {
+			return j
+		}
+
+		j += searcher.shift(last)
+	}
+
+	return -1
+}
@@ -0,0 +1,96 @@
+package ignore
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Return the closest directory from absDir up that contains ".git"
+func FindRepositoryRoot(absDir string) (string, bool) {
+	for dir := absDir; ; dir = filepath.Dir(dir) {
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return dir, true
+		}
+		if filepath.Dir(dir) == dir {
+			return "", false
+		}
+	}
+}
+
+// Path of the global excludes file, core.excludesFile of the user git config or its default location
+func GlobalExcludesFile() string {
+	home, _ := os.UserHomeDir()
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if configHome == "" && home != "" {
+		configHome = filepath.Join(home, ".config")
+	}
+
+	// ~/.gitconfig is read last so it overrides the XDG config like in git
+	excludesFile := ""
+	for _, config := range []string{filepath.Join(configHome, "git", "config"), filepath.Join(home, ".gitconfig")} {
+		if value, isFound := readCoreExcludesFile(config); isFound {
+			excludesFile = value
+		}
+	}
+
+	if excludesFile == "" {
+		if configHome == "" {
+			return ""
+		}
+		return filepath.Join(configHome, "git", "ignore")
+	}
+	if excludesFile == "~" || strings.HasPrefix(excludesFile, "~/") {
+		excludesFile = filepath.Join(home, excludesFile[1:])
+	}
+	return excludesFile
+}
+
+// Minimal git config reader, only the excludesFile key of the [core] section is looked for
+func readCoreExcludesFile(config string) (string, bool) {
+	fp, err := os.Open(config)
+	if err != nil {
+		return "", false
+	}
+	defer fp.Close()
+
+	value := ""
+	isFound := false
+	isInCore := false
+	scanner := bufio.NewScanner(fp)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			isInCore = strings.EqualFold(strings.Trim(line, "[] \t"), "core")
+			continue
+		}
+
+		key, v, hasValue := strings.Cut(line, "=")
+		if isInCore && hasValue && strings.EqualFold(strings.TrimSpace(key), "excludesfile") {
+			value = strings.Trim(strings.TrimSpace(v), `"`)
+			isFound = true
+		}
+	}
+
+	return value, isFound
+}
+
+// Ignore files of a repository that do not belong to a directory of the tree: the global excludes and
+// .git/info/exclude, both relative to the repository root
+func LoadRepositoryPatternLists(repoRoot string) []*PatternList {
+	lists := make([]*PatternList, 0, 2)
+	for _, file := range []string{GlobalExcludesFile(), filepath.Join(repoRoot, ".git", "info", "exclude")} {
+		if file == "" {
+			continue
+		}
+		if list, err := LoadPatternList(file, repoRoot); err == nil {
+			lists = append(lists, list)
+		}
+	}
+
+	return lists
+}
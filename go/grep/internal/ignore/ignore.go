@@ -0,0 +1,187 @@
+package ignore
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// One line of an ignore file, with the gitignore semantics
+type pattern struct {
+	segments   []string // glob of each path segment, "**" matches any number of segments
+	isNegated  bool     // "!pattern" re-includes what a previous pattern excluded
+	isDirOnly  bool     // "pattern/" only matches directories
+	isAnchored bool     // a pattern with a slash is relative to the ignore file, otherwise it matches at any depth
+}
+
+// Parse one line, return false for blank lines and comments
+func parsePattern(line string) (pattern, bool) {
+	line = strings.TrimSuffix(line, "\r")
+	line = trimTrailingSpaces(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return pattern{}, false
+	}
+
+	p := pattern{}
+	if strings.HasPrefix(line, "!") {
+		p.isNegated = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+		line = line[1:]
+	}
+
+	if strings.HasSuffix(line, "/") {
+		p.isDirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if line == "" {
+		return pattern{}, false
+	}
+
+	p.isAnchored = strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+	for _, segment := range strings.Split(line, "/") {
+		if segment == "" {
+			continue // "a//b" is "a/b"
+		}
+		// gitignore negates a bracket expression with "!", path.Match with "^"
+		p.segments = append(p.segments, strings.ReplaceAll(segment, "[!", "[^"))
+	}
+
+	return p, len(p.segments) > 0
+}
+
+// Trailing spaces are ignored unless they are escaped with a backslash
+func trimTrailingSpaces(line string) string {
+	trimmed := strings.TrimRight(line, " ")
+	if len(trimmed) < len(line) && strings.HasSuffix(trimmed, `\`) {
+		return trimmed[:len(trimmed)-1] + " "
+	}
+	return trimmed
+}
+
+// Match the slash separated path relative to the ignore file
+func (p pattern) matches(relPath string, isDir bool) bool {
+	if p.isDirOnly && !isDir {
+		return false
+	}
+
+	names := strings.Split(relPath, "/")
+	if !p.isAnchored { // only the name of the last segment is matched
+		return matchSegments(p.segments, names[len(names)-1:])
+	}
+	return matchSegments(p.segments, names)
+}
+
+func matchSegments(segments []string, names []string) bool {
+	if len(segments) == 0 {
+		return len(names) == 0
+	}
+
+	if segments[0] == "**" {
+		if len(segments) == 1 { // a trailing "/**" matches everything inside, but not the directory itself
+			return len(names) > 0
+		}
+		for i := 0; i <= len(names); i++ {
+			if matchSegments(segments[1:], names[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if len(names) == 0 {
+		return false
+	}
+	isMatched, err := path.Match(segments[0], names[0])
+	if err != nil || !isMatched { // a malformed glob never matches
+		return false
+	}
+
+	return matchSegments(segments[1:], names[1:])
+}
+
+// The patterns of one ignore file, relative to BaseDir
+type PatternList struct {
+	BaseDir  string
+	patterns []pattern
+}
+
+func ParsePatternList(r io.Reader, baseDir string) (*PatternList, error) {
+	list := &PatternList{BaseDir: baseDir}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if p, isPattern := parsePattern(scanner.Text()); isPattern {
+			list.patterns = append(list.patterns, p)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+// Load an ignore file, a missing file is an empty list
+func LoadPatternList(filepath string, baseDir string) (*PatternList, error) {
+	fp, err := os.Open(filepath)
+	if os.IsNotExist(err) {
+		return &PatternList{BaseDir: baseDir}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer fp.Close()
+
+	return ParsePatternList(fp, baseDir)
+}
+
+// Return whether a pattern matches the path, and if so whether the path is ignored by the last one
+func (list *PatternList) match(absPath string, isDir bool) (isMatched bool, isIgnored bool) {
+	relPath, err := filepath.Rel(list.BaseDir, absPath)
+	if err != nil || relPath == "." || relPath == ".." || strings.HasPrefix(relPath, ".."+string(filepath.Separator)) {
+		return false, false // not below the ignore file
+	}
+	relPath = filepath.ToSlash(relPath)
+
+	for i := len(list.patterns) - 1; i >= 0; i-- {
+		if list.patterns[i].matches(relPath, isDir) {
+			return true, !list.patterns[i].isNegated
+		}
+	}
+
+	return false, false
+}
+
+// Ignore files from the lowest to the highest precedence, e.g. the global excludes, then the ignore
+// files of the parent directories down to the deepest one
+type Matcher struct {
+	lists []*PatternList
+}
+
+// Return a new matcher where the lists have precedence over the ones of m, m is left unchanged
+func (m *Matcher) With(lists ...*PatternList) *Matcher {
+	combined := make([]*PatternList, 0, len(m.lists)+len(lists))
+	combined = append(combined, m.lists...)
+	for _, list := range lists {
+		if len(list.patterns) > 0 {
+			combined = append(combined, list)
+		}
+	}
+
+	return &Matcher{lists: combined}
+}
+
+func (m *Matcher) IsIgnored(absPath string, isDir bool) bool {
+	for i := len(m.lists) - 1; i >= 0; i-- {
+		if isMatched, isIgnored := m.lists[i].match(absPath, isDir); isMatched {
+			return isIgnored
+		}
+	}
+
+	return false
+}
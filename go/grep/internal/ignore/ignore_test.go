@@ -0,0 +1,117 @@
+package ignore
+
+import (
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParsePattern(t *testing.T) {
+	cases := []struct {
+		line      string
+		isPattern bool
+		expected  pattern
+	}{
+		{"", false, pattern{}},
+		{"# comment", false, pattern{}},
+		{"   ", false, pattern{}},
+		{"/", false, pattern{}},
+		{"*.log", true, pattern{segments: []string{"*.log"}}},
+		{"*.log\r", true, pattern{segments: []string{"*.log"}}},
+		{"!keep.log", true, pattern{segments: []string{"keep.log"}, isNegated: true}},
+		{`\!keep.log`, true, pattern{segments: []string{"!keep.log"}}},
+		{`\#hash`, true, pattern{segments: []string{"#hash"}}},
+		{"tmp/", true, pattern{segments: []string{"tmp"}, isDirOnly: true}},
+		{"/build", true, pattern{segments: []string{"build"}, isAnchored: true}},
+		{"doc/frotz/", true, pattern{segments: []string{"doc", "frotz"}, isDirOnly: true, isAnchored: true}},
+		{"a//b", true, pattern{segments: []string{"a", "b"}, isAnchored: true}},
+		{"**/foo", true, pattern{segments: []string{"**", "foo"}, isAnchored: true}},
+		{"trailing   ", true, pattern{segments: []string{"trailing"}}},
+		{`space\ `, true, pattern{segments: []string{"space "}}},
+		{"[!a]b", true, pattern{segments: []string{"[^a]b"}}},
+	}
+
+	for _, c := range cases {
+		p, isPattern := parsePattern(c.line)
+		if isPattern != c.isPattern {
+			t.Errorf("%q: got a pattern %v, expected %v", c.line, isPattern, c.isPattern)
+			continue
+		}
+		if isPattern && (!slices.Equal(p.segments, c.expected.segments) || p.isNegated != c.expected.isNegated ||
+			p.isDirOnly != c.expected.isDirOnly || p.isAnchored != c.expected.isAnchored) {
+			t.Errorf("%q: got %+v, expected %+v", c.line, p, c.expected)
+		}
+	}
+}
+
+func TestMatchSegments(t *testing.T) {
+	cases := []struct {
+		segments string
+		names    string
+		expected bool
+	}{
+		{"a/b", "a/b", true},
+		{"a/b", "a/b/c", false},
+		{"a*", "abc", true},
+		{"**/foo", "foo", true},
+		{"**/foo", "a/b/foo", true},
+		{"**/foo", "a/foo/b", false},
+		{"a/**", "a/b/c", true},
+		{"a/**", "a", false}, // the directory itself is not inside
+		{"a/**/b", "a/b", true},
+		{"a/**/b", "a/x/y/b", true},
+		{"a/**/b", "a/x/y/c", false},
+		{"[^a]b", "cb", true},
+		{"[^a]b", "ab", false},
+		{"[", "[", false}, // malformed
+	}
+
+	for _, c := range cases {
+		if isMatched := matchSegments(strings.Split(c.segments, "/"), strings.Split(c.names, "/")); isMatched != c.expected {
+			t.Errorf("%s in %s: got %v, expected %v", c.segments, c.names, isMatched, c.expected)
+		}
+	}
+}
+
+func TestMatcherIsIgnored(t *testing.T) {
+	root := filepath.FromSlash("/repo")
+	parse := func(content string, baseDir string) *PatternList {
+		list, err := ParsePatternList(strings.NewReader(content), baseDir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return list
+	}
+	matcher := (&Matcher{}).With(
+		parse("*.log\n!keep.log\n/build\ntmp/\ndoc/**/*.txt\n", root),
+		parse("!c.log\n", filepath.Join(root, "sub")),
+	)
+
+	cases := []struct {
+		path     string
+		isDir    bool
+		expected bool
+	}{
+		{"/repo/a.log", false, true},
+		{"/repo/sub/deep/b.log", false, true},
+		{"/repo/keep.log", false, false},  // negated by a later pattern
+		{"/repo/sub/c.log", false, false}, // negated by a deeper ignore file
+		{"/repo/c.log", false, true},
+		{"/repo/build", true, true},
+		{"/repo/sub/build", true, false}, // anchored to the ignore file
+		{"/repo/tmp", true, true},
+		{"/repo/tmp", false, false}, // only a directory
+		{"/repo/sub/tmp", true, true},
+		{"/repo/doc/a.txt", false, true},
+		{"/repo/doc/x/y/a.txt", false, true},
+		{"/repo/a.txt", false, false},
+		{"/other/a.log", false, false}, // not below the ignore files
+	}
+
+	for _, c := range cases {
+		if isIgnored := matcher.IsIgnored(filepath.FromSlash(c.path), c.isDir); isIgnored != c.expected {
+			t.Errorf("%s (directory %v): got %v, expected %v", c.path, c.isDir, isIgnored, c.expected)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package match
+
+import "unicode"
+
+type runeRange struct {
+	Lo rune
+	Hi rune
+}
+
+type charClass struct {
+	ranges     []runeRange
+	tables     []*unicode.RangeTable
+	subclasses []*charClass // e.g. "\D" inside a Perl bracket
+	negated    bool
+}
+
+func (class *charClass) addRange(lo rune, hi rune) {
+	class.ranges = append(class.ranges, runeRange{Lo: lo, Hi: hi})
+}
+
+func (class *charClass) containsExact(r rune) bool {
+	for _, rr := range class.ranges {
+		if rr.Lo <= r && r <= rr.Hi {
+			return true
+		}
+	}
+
+	for _, subclass := range class.subclasses {
+		if subclass.containsExact(r) != subclass.negated {
+			return true
+		}
+	}
+
+	return unicode.IsOneOf(class.tables, r)
+}
+
+func (class *charClass) matches(r rune, isCaseInsensitive bool) bool {
+	isContained := class.containsExact(r)
+	if !isContained && isCaseInsensitive {
+		// try every other case of the rune, e.g. 'k' -> 'K' -> 'K' (Kelvin sign)
+		for folded := unicode.SimpleFold(r); folded != r; folded = unicode.SimpleFold(folded) {
+			if class.containsExact(folded) {
+				isContained = true
+				break
+			}
+		}
+	}
+
+	return isContained != class.negated
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
@@ -0,0 +1,93 @@
+package match
+
+import (
+	"fmt"
+
+	"github.com/ikraduya/codingchallanges/go/grep/comparisonutils"
+	"github.com/ikraduya/codingchallanges/go/grep/myers"
+)
+
+// Matcher of --fuzzy, the patterns are fixed strings matched within maxDistance edits
+type fuzzyMatcher struct {
+	patterns        [][]rune
+	comparisonFuncs []comparisonutils.RuneComparisonFunc
+	searchers       []*myers.Searcher
+	maxDistance     int
+}
+
+func CompileFuzzyPatterns(patterns []string, maxDistance int, expOptions ExpressionOption) (CheckContainsOperation, error) {
+	if len(patterns) == 0 {
+		return containsNothing, nil
+	}
+
+	parsed, err := parseRegexList(patterns, SyntaxFixed)
+	if err != nil {
+		return nil, err
+	}
+	expOptions, isMixedCase := resolveSmartCase(parsed, expOptions)
+	if isMixedCase {
+		return compileMixedCase(patterns, parsed, expOptions, func(patterns []string, expOptions ExpressionOption) (CheckContainsOperation, error) {
+			return CompileFuzzyPatterns(patterns, maxDistance, expOptions)
+		})
+	}
+
+	transform := newTextTransform(expOptions)
+	m := &fuzzyMatcher{maxDistance: maxDistance}
+	for _, pattern := range patterns {
+		comparisonFunc, foldFunc := runeFuncs(expOptions.IsCaseInsensitive)
+		runes := []rune(pattern)
+		if transform != nil {
+			runes = transform.runes(runes)
+		}
+		if len(runes) <= maxDistance { // the empty string would match everywhere
+			return nil, fmt.Errorf("the pattern %q must be longer than the distance %d of --fuzzy", pattern, maxDistance)
+		}
+		m.patterns = append(m.patterns, runes)
+		m.comparisonFuncs = append(m.comparisonFuncs, comparisonFunc)
+		m.searchers = append(m.searchers, myers.NewSearcher(runes, maxDistance, comparisonFunc, foldFunc))
+	}
+
+	var checkContainsFunc CheckContainsOperation = m.Contains
+	if expOptions.IsLineMatch {
+		checkContainsFunc = func(s []rune, exp []rune, expOptions ExpressionOption) []IndexRange {
+			s = trimLineEndingEnd(s)
+			for i, pattern := range m.patterns {
+				if myers.Distance(pattern, s, m.comparisonFuncs[i]) <= maxDistance {
+					return []IndexRange{{Start: 0, Stop: len(s)}}
+				}
+			}
+			return nil
+		}
+	}
+
+	if transform != nil {
+		return transform.wrap(checkContainsFunc), nil
+	}
+	return checkContainsFunc, nil
+}
+
+// The matches of every pattern, the ones overlapping an earlier match are dropped
+func (m *fuzzyMatcher) Contains(s []rune, exp []rune, expOptions ExpressionOption) []IndexRange {
+	s = trimLineEndingEnd(s)
+
+	indexRanges := make([]IndexRange, 0)
+	for _, searcher := range m.searchers {
+		for pos := 0; pos < len(s); {
+			start, stop := searcher.Index(s, pos)
+			if start < 0 {
+				break
+			}
+			if !isMatchBoundaryValid(s, start, stop, expOptions) {
+				pos = start + 1 // a valid match may start inside the rejected one
+				continue
+			}
+			indexRanges = append(indexRanges, IndexRange{Start: start, Stop: stop})
+			pos = stop
+		}
+	}
+	if len(indexRanges) == 0 {
+		return nil
+	}
+
+	return mergeIndexRanges(indexRanges)
+}
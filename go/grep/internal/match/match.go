@@ -1,15 +1,23 @@
 package match
 
 import (
-	"ccgrep/comparisonutils"
-	"ccgrep/kmp"
+	"slices"
 
-	"unicode"
+	"github.com/ikraduya/codingchallanges/go/grep/internal/normalize"
 )
 
 type ExpressionOption struct {
 	IsInvertExpression bool
 	IsCaseInsensitive  bool
+	IsSmartCase        bool // a pattern without an upper case letter is case insensitive, see resolveSmartCase
+	IsWordMatch        bool // a match must not be preceded or followed by a word character
+	IsLineMatch        bool // a match must cover the whole line
+	IsDotAll           bool // "." also matches a newline, e.g. inside a NUL-terminated line of -z
+	IsMultiline        bool // the input holds several lines, "^" and "$" also match next to a newline
+	IsNullData         bool // the lines of IsMultiline are terminated by NUL, "^" and "$" also match next to it
+	IsMatchOnly        bool // the caller of a CheckContainsOperation only needs to know whether a line matches
+
+	Normalization normalize.Form // the lines and the patterns are matched in this form, see textTransform
 }
 
 type IndexRange struct {
@@ -17,159 +25,35 @@ type IndexRange struct {
 	Stop  int
 }
 
-// Contract: return nil if no match; return a non-empty slice if matched.
+// Contract: return nil if no match; return a non-empty slice if matched. With IsMatchOnly in expOptions,
+// the slice may be any range of s instead of the matches.
 type CheckContainsOperation func(s []rune, exp []rune, expOptions ExpressionOption) []IndexRange
 
-func ContainsExpression(s []rune, exp []rune, expOptions ExpressionOption) []IndexRange {
-	indexRanges := make([]IndexRange, 0)
-	if len(exp) == 0 {
-		indexRanges = append(indexRanges, IndexRange{Start: 0, Stop: len(s)})
-		return indexRanges
-	}
-
-	runeComparisonFunc := comparisonutils.AreRunesCaseSensitiveEqual
-	if expOptions.IsCaseInsensitive {
-		runeComparisonFunc = comparisonutils.AreRunesCaseInsensitiveEqual
-	}
-
-	// Using knuth morris prat
-	lps := kmp.ComputeLPSArray(exp, runeComparisonFunc)
-
-	sLen := len(s)
-	patLen := len(exp)
-
-	sIdx := 0
-	patIdx := 0
-	for sIdx < sLen {
-		// if character match, move pointer forward
-		if runeComparisonFunc(s[sIdx], exp[patIdx]) {
-			sIdx += 1
-			patIdx += 1
-
-			// entire pattern is match
-			if patIdx == patLen {
-				startIdx := sIdx - patIdx
-				indexRanges = append(indexRanges, IndexRange{Start: startIdx, Stop: startIdx + patLen})
-
-				patIdx = lps[patIdx-1]
-			}
-		} else {
-			// use lps of previous index
-			if patIdx != 0 {
-				patIdx = lps[patIdx-1]
-			} else {
-				sIdx += 1
-			}
-		}
-	}
-
-	if len(indexRanges) > 0 {
-		return indexRanges
-	} else {
-		return nil
-	}
-}
-
-func ContainsDigit(s []rune, exp []rune, expOptions ExpressionOption) []IndexRange {
-	indexRanges := make([]IndexRange, 0)
-
-	indexStart := -1
-	indexStop := -1
-
-	for idx, r := range s {
-		if unicode.IsDigit(r) {
-			if indexStop == -1 {
-				indexStart = idx
-				indexStop = idx
-			} else {
-				indexStop = idx
-			}
-		} else { // not digit
-			if indexStart != -1 { // currently in a range, so stop the range and store it
-				indexStop = idx
-				indexRanges = append(indexRanges, IndexRange{Start: indexStart, Stop: indexStop})
-
-				indexStart = -1
-				indexStop = -1
-			}
-		}
-	}
-	if indexStart != -1 {
-		indexStop += 1
-		indexRanges = append(indexRanges, IndexRange{Start: indexStart, Stop: indexStop})
-	}
-
-	if len(indexRanges) > 0 {
-		return indexRanges
-	} else {
-		return nil
-	}
-}
-
-func ContainsWordCharacter(s []rune, exp []rune, expOptions ExpressionOption) []IndexRange {
-	indexRanges := make([]IndexRange, 0)
-
-	indexStart := -1
-	indexStop := -1
-
-	wordChars := []*unicode.RangeTable{unicode.Digit, unicode.Letter}
-
-	for idx, r := range s {
-		if unicode.IsOneOf(wordChars, r) {
-			if indexStop == -1 {
-				indexStart = idx
-				indexStop = idx
-			} else {
-				indexStop = idx
-			}
-		} else { // not digit
-			if indexStart != -1 { // currently in a range, so stop the range and store it
-				indexStop = idx
-				indexRanges = append(indexRanges, IndexRange{Start: indexStart, Stop: indexStop})
-
-				indexStart = -1
-				indexStop = -1
-			}
+// Sort the matches of several matchers and drop the ones overlapping an earlier match, the longest of the
+// matches starting at the same rune is kept. indexRanges is not empty.
+func mergeIndexRanges(indexRanges []IndexRange) []IndexRange {
+	slices.SortFunc(indexRanges, func(a IndexRange, b IndexRange) int {
+		if a.Start != b.Start {
+			return a.Start - b.Start
 		}
-	}
-	if indexStart != -1 {
-		indexStop += 1
-		indexRanges = append(indexRanges, IndexRange{Start: indexStart, Stop: indexStop})
-	}
-
-	if len(indexRanges) > 0 {
-		return indexRanges
-	} else {
-		return nil
-	}
-}
-
-func ContainsBeginningExpression(s []rune, exp []rune, expOptions ExpressionOption) []IndexRange {
-	indexRange := make([]IndexRange, 0, 1)
-
-	runeComparisonFunc := comparisonutils.AreRunesCaseSensitiveEqual
-	if expOptions.IsCaseInsensitive {
-		runeComparisonFunc = comparisonutils.AreRunesCaseInsensitiveEqual
-	}
-
-	expLen := len(exp)
-	sLen := len(s)
-	if expLen > sLen {
-		return nil
-	}
-
-	for i := 0; i < expLen; i += 1 {
-		if !runeComparisonFunc(s[i], exp[i]) {
-			return nil
+		return b.Stop - a.Stop // the longest first
+	})
+	merged := indexRanges[:1]
+	for _, indexRange := range indexRanges[1:] {
+		if indexRange.Start >= merged[len(merged)-1].Stop {
+			merged = append(merged, indexRange)
 		}
 	}
 
-	indexRange = append(indexRange, IndexRange{Start: 0, Stop: len(exp)})
-	return indexRange
+	return merged
 }
 
+// Trim "\n", "\r\n" or the NUL that ends a line with -z
 func trimLineEndingEnd(s []rune) []rune {
 	endIdx := len(s)
+	if endIdx > 0 && s[endIdx-1] == 0 {
+		return s[:endIdx-1]
+	}
 	if endIdx > 0 && s[endIdx-1] == '\n' {
 		endIdx--
 		if endIdx > 0 && s[endIdx-1] == '\r' {
@@ -180,29 +64,19 @@ func trimLineEndingEnd(s []rune) []rune {
 	return s[:endIdx]
 }
 
-func ContainsEndingExpression(s []rune, exp []rune, expOptions ExpressionOption) []IndexRange {
-	indexRange := make([]IndexRange, 0, 1)
-
-	runeComparisonFunc := comparisonutils.AreRunesCaseSensitiveEqual
-	if expOptions.IsCaseInsensitive {
-		runeComparisonFunc = comparisonutils.AreRunesCaseInsensitiveEqual
+// Check the -w and -x constraints of a match in s, s has no line ending
+func isMatchBoundaryValid(s []rune, start int, stop int, expOptions ExpressionOption) bool {
+	if expOptions.IsLineMatch && (start != 0 || stop != len(s)) {
+		return false
 	}
-
-	s = trimLineEndingEnd(s)
-
-	expLen := len(exp)
-	sLen := len(s)
-	if expLen > sLen {
-		return nil
-	}
-
-	for i := 0; i < expLen; i += 1 {
-		sIdx := sLen - expLen + i
-		if !runeComparisonFunc(s[sIdx], exp[i]) {
-			return nil
+	if expOptions.IsWordMatch {
+		if start > 0 && isWordRune(s[start-1]) {
+			return false
+		}
+		if stop < len(s) && isWordRune(s[stop]) {
+			return false
 		}
 	}
 
-	indexRange = append(indexRange, IndexRange{Start: len(s) - len(exp), Stop: len(s)})
-	return indexRange
+	return true
 }
@@ -0,0 +1,148 @@
+package match
+
+import (
+	"strings"
+
+	"github.com/ikraduya/codingchallanges/go/grep/ahocorasick"
+	"github.com/ikraduya/codingchallanges/go/grep/comparisonutils"
+)
+
+type fixedStringsMatcher struct {
+	automaton       *ahocorasick.Automaton
+	hasEmptyPattern bool
+	expOptions      ExpressionOption
+}
+
+func newFixedStringsMatcher(literals [][]rune, expOptions ExpressionOption) *fixedStringsMatcher {
+	foldFunc := comparisonutils.FoldRuneCaseSensitive
+	if expOptions.IsCaseInsensitive {
+		foldFunc = comparisonutils.FoldRuneCaseInsensitive
+	}
+
+	m := &fixedStringsMatcher{expOptions: expOptions}
+	nonEmptyLiterals := make([][]rune, 0, len(literals))
+	for _, literal := range literals {
+		if len(literal) == 0 {
+			m.hasEmptyPattern = true
+			continue
+		}
+		nonEmptyLiterals = append(nonEmptyLiterals, literal)
+	}
+	m.automaton = ahocorasick.Build(nonEmptyLiterals, foldFunc)
+
+	return m
+}
+
+// Satisfy CheckContainsOperation, exp is ignored since the patterns are in the automaton
+func (m *fixedStringsMatcher) Contains(s []rune, exp []rune, expOptions ExpressionOption) []IndexRange {
+	s = trimLineEndingEnd(s)
+
+	var accept func(match ahocorasick.Match) bool
+	if m.expOptions.IsWordMatch || m.expOptions.IsLineMatch {
+		accept = func(match ahocorasick.Match) bool {
+			return isMatchBoundaryValid(s, match.Start, match.Stop, m.expOptions)
+		}
+	}
+	matches := m.automaton.FindAll(s, accept)
+
+	indexRanges := make([]IndexRange, 0, len(matches))
+	for _, match := range matches {
+		indexRanges = append(indexRanges, IndexRange{Start: match.Start, Stop: match.Stop})
+	}
+
+	if len(indexRanges) == 0 && m.hasEmptyPattern {
+		// an empty pattern matches every line, unless -w or -x rule out every position
+		for pos := 0; pos <= len(s); pos++ {
+			if isMatchBoundaryValid(s, pos, pos, m.expOptions) {
+				return []IndexRange{{Start: pos, Stop: pos}}
+			}
+		}
+	}
+
+	if len(indexRanges) == 0 {
+		return nil
+	}
+	return indexRanges
+}
+
+func containsNothing(s []rune, exp []rune, expOptions ExpressionOption) []IndexRange {
+	return nil
+}
+
+// Build one CheckContainsOperation that matches any of the patterns. The literals, including regexes only
+// made of literals like "foo|bar", skip the regex engine. Everything else is compiled into one regex.
+func CompilePatterns(patterns []string, syntax RegexSyntax, expOptions ExpressionOption) (CheckContainsOperation, error) {
+	if len(patterns) == 0 { // e.g. "-f /dev/null"
+		return containsNothing, nil
+	}
+
+	parsed, err := parseRegexList(patterns, syntax)
+	if err != nil {
+		return nil, err
+	}
+	expOptions, isMixedCase := resolveSmartCase(parsed, expOptions)
+	if isMixedCase {
+		return compileMixedCase(patterns, parsed, expOptions, func(patterns []string, expOptions ExpressionOption) (CheckContainsOperation, error) {
+			return CompilePatterns(patterns, syntax, expOptions)
+		})
+	}
+
+	if literals, isLiteral := literalAlternatives(parsed.root); isLiteral {
+		transform := newTextTransform(expOptions)
+		if transform == nil {
+			return compileLiterals(literals, expOptions), nil
+		}
+
+		for i, literal := range literals {
+			literals[i] = transform.runes(literal)
+		}
+		return transform.wrap(compileLiterals(literals, expOptions)), nil
+	}
+
+	re, err := compileParsedRegex(strings.Join(patterns, "\n"), parsed, syntax, expOptions)
+	if err != nil {
+		return nil, err
+	}
+	return re.Contains, nil
+}
+
+// A single literal uses a SubstringSearcher and several literals (or a literal with -w or -x) use an
+// Aho-Corasick automaton
+func compileLiterals(literals [][]rune, expOptions ExpressionOption) CheckContainsOperation {
+	if len(literals) > 1 || expOptions.IsWordMatch || expOptions.IsLineMatch {
+		return newFixedStringsMatcher(literals, expOptions).Contains
+	}
+
+	literal := literals[0]
+	if len(literal) == 0 { // an empty pattern matches every line, without highlighting it
+		return func(s []rune, exp []rune, expOptions ExpressionOption) []IndexRange {
+			return []IndexRange{{Start: 0, Stop: 0}}
+		}
+	}
+
+	// the searcher is built once, not once per line
+	algorithm := SelectSubstringAlgorithm(literal, expOptions.IsCaseInsensitive)
+	searcher := NewSubstringSearcher(literal, expOptions.IsCaseInsensitive, algorithm)
+	return func(s []rune, exp []rune, expOptions ExpressionOption) []IndexRange {
+		return FindAllSubstrings(s, searcher, len(literal))
+	}
+}
+
+// Return the literals of a node that is either a literal or an alternation of literals
+func literalAlternatives(node *regexNode) ([][]rune, bool) {
+	if node.kind != nodeAlternate {
+		literal, isLiteral := literalRunes(node)
+		return [][]rune{literal}, isLiteral
+	}
+
+	literals := make([][]rune, 0, len(node.children))
+	for _, child := range node.children {
+		childLiterals, isLiteral := literalAlternatives(child)
+		if !isLiteral {
+			return nil, false
+		}
+		literals = append(literals, childLiterals...)
+	}
+
+	return literals, true
+}
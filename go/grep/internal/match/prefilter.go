@@ -0,0 +1,233 @@
+package match
+
+import (
+	"bytes"
+	"slices"
+	"unicode/utf8"
+
+	"github.com/ikraduya/codingchallanges/go/grep/ahocorasick"
+	"github.com/ikraduya/codingchallanges/go/grep/comparisonutils"
+	"github.com/ikraduya/codingchallanges/go/grep/internal/normalize"
+)
+
+// Literals that every matching line contains, searched on the raw input so that the lines without any of
+// them are skipped before they are decoded into runes. A few literals are searched with bytes.Index, more
+// of them (or with isASCIIOnly) with an Aho-Corasick automaton.
+type Prefilter struct {
+	literals  [][]byte
+	automaton *ahocorasick.ByteAutomaton // nil when the literals are searched with bytes.Index
+	isExact   bool                       // a line matches exactly when it contains one of the literals
+
+	// with -i or --normalize, folded or normalized runes have other encodings, e.g. 'k' and the Kelvin
+	// sign: only the ASCII literals are kept and every line with a non-ASCII byte is a candidate
+	isASCIIOnly bool
+}
+
+// Past this many literals, searching each literal with bytes.Index costs more than the automaton
+const maxPrefilterLiterals = 8
+
+// Upper bound of the total length of the literals, that bounds the size of the automaton
+const maxPrefilterSize = 1 << 16
+
+// Return the prefilter of the patterns, or nil when no literal is required (e.g. "[0-9]+")
+func NewPrefilter(patterns []string, syntax RegexSyntax, expOptions ExpressionOption) *Prefilter {
+	if len(patterns) == 0 {
+		return nil
+	}
+
+	parsed, err := parseRegexList(patterns, syntax)
+	if err != nil {
+		return nil
+	}
+	expOptions, isMixedCase := resolveSmartCase(parsed, expOptions)
+
+	// the lines are matched on their transformed runes, so are the literals, e.g. 'ß' is "ss" with -i
+	isFolded := expOptions.IsCaseInsensitive || isMixedCase
+	isASCIIOnly := isFolded || expOptions.Normalization != normalize.FormNone
+	root := parsed.root
+	if isASCIIOnly {
+		root = (&textTransform{form: expOptions.Normalization, isFolded: isFolded}).literals(root)
+	}
+
+	runeLiterals, isRequired := requiredLiterals(root)
+	if !isRequired {
+		return nil
+	}
+
+	p := &Prefilter{literals: make([][]byte, 0, len(runeLiterals)), isASCIIOnly: isASCIIOnly}
+	size := 0
+	for _, literal := range runeLiterals {
+		if isFolded {
+			literal = slices.Clone(literal)
+			for i, r := range literal {
+				literal[i] = comparisonutils.FoldRuneCaseInsensitive(r) // e.g. the Kelvin sign is 'k'
+			}
+		}
+
+		isASCII := true
+		for _, r := range literal {
+			// the line ending is trimmed before matching
+			if r == '\r' || r == '\n' {
+				return nil
+			}
+			isASCII = isASCII && r < utf8.RuneSelf
+		}
+		if isASCIIOnly && !isASCII {
+			continue // only matches a line with a non-ASCII byte
+		}
+		if slices.Contains(literal, utf8.RuneError) { // invalid bytes are decoded as RuneError
+			return nil
+		}
+
+		p.literals = append(p.literals, []byte(string(literal)))
+		size += len(p.literals[len(p.literals)-1])
+	}
+	if size > maxPrefilterSize {
+		return nil
+	}
+	if len(p.literals) > maxPrefilterLiterals || isASCIIOnly {
+		p.automaton = ahocorasick.BuildBytes(p.literals, isFolded, isASCIIOnly)
+	}
+
+	_, isLiteral := literalAlternatives(parsed.root)
+	p.isExact = isLiteral && !isASCIIOnly && !expOptions.IsWordMatch && !expOptions.IsLineMatch
+	return p
+}
+
+// Return literals such that every matching line contains one of them, as UTF-8, for the trigram index.
+// isFolded tells that they match case insensitively, so their matches outside ASCII may have other
+// encodings. Return false when no literal is required, e.g. with -v or --normalize.
+func RequiredLiterals(patterns []string, syntax RegexSyntax, expOptions ExpressionOption) ([][]byte, bool, bool) {
+	if len(patterns) == 0 || expOptions.IsInvertExpression || expOptions.Normalization != normalize.FormNone {
+		return nil, false, false
+	}
+
+	parsed, err := parseRegexList(patterns, syntax)
+	if err != nil {
+		return nil, false, false
+	}
+	expOptions, isMixedCase := resolveSmartCase(parsed, expOptions)
+
+	runeLiterals, isRequired := requiredLiterals(parsed.root)
+	if !isRequired {
+		return nil, false, false
+	}
+
+	literals := make([][]byte, 0, len(runeLiterals))
+	for _, literal := range runeLiterals {
+		if slices.Contains(literal, utf8.RuneError) { // matches the invalid bytes
+			return nil, false, false
+		}
+		literals = append(literals, []byte(string(literal)))
+	}
+	return literals, expOptions.IsCaseInsensitive || isMixedCase, true
+}
+
+func (p *Prefilter) IsExact() bool {
+	return p.isExact
+}
+
+// Length in bytes of the longest literal, a literal may start that many bytes minus one before the end
+// of a block and continue in the next one
+func (p *Prefilter) MaxLiteralLen() int {
+	maxLen := 1 // a non-ASCII byte with isASCIIOnly
+	for _, literal := range p.literals {
+		maxLen = max(maxLen, len(literal))
+	}
+
+	return maxLen
+}
+
+// Return the index of a literal in data, or of a non-ASCII byte with isASCIIOnly, no line before the line
+// of the index has one. Return -1 if there is none.
+func (p *Prefilter) Index(data []byte) int {
+	if p.automaton != nil {
+		return p.automaton.Index(data)
+	}
+
+	first := -1
+	for _, literal := range p.literals {
+		// only the part before the best hit so far can have a better one
+		searched := data
+		if first >= 0 && first+len(literal)-1 < len(data) {
+			searched = data[:first+len(literal)-1]
+		}
+
+		if i := bytes.Index(searched, literal); i >= 0 {
+			first = i
+		}
+	}
+
+	return first
+}
+
+// Return a set of literals such that any match of the node contains one of them
+func requiredLiterals(node *regexNode) ([][]rune, bool) {
+	switch node.kind {
+	case nodeLiteral:
+		return [][]rune{{node.r}}, true
+	case nodeCapture, nodeGroup:
+		return requiredLiterals(node.children[0])
+	case nodeRepeat:
+		if node.min == 0 {
+			return nil, false
+		}
+		return requiredLiterals(node.children[0])
+	case nodeAlternate:
+		literals := make([][]rune, 0, len(node.children))
+		for _, child := range node.children {
+			childLiterals, isRequired := requiredLiterals(child)
+			if !isRequired {
+				return nil, false
+			}
+			literals = append(literals, childLiterals...)
+		}
+		return literals, true
+	case nodeConcat:
+		return requiredConcatLiterals(node.children)
+	}
+
+	return nil, false
+}
+
+// A run of consecutive literals is one required literal, the best of the runs and of the required
+// literals of the other children is kept
+func requiredConcatLiterals(children []*regexNode) ([][]rune, bool) {
+	var best [][]rune
+	consider := func(literals [][]rune) {
+		if best == nil || shortestLength(literals) > shortestLength(best) {
+			best = literals
+		}
+	}
+
+	run := make([]rune, 0)
+	for _, child := range children {
+		if child.kind == nodeLiteral {
+			run = append(run, child.r)
+			continue
+		}
+		if len(run) > 0 {
+			consider([][]rune{run})
+			run = make([]rune, 0)
+		}
+		if childLiterals, isRequired := requiredLiterals(child); isRequired {
+			consider(childLiterals)
+		}
+	}
+	if len(run) > 0 {
+		consider([][]rune{run})
+	}
+
+	return best, best != nil
+}
+
+func shortestLength(literals [][]rune) int {
+	shortest := -1
+	for _, literal := range literals {
+		if shortest == -1 || len(literal) < shortest {
+			shortest = len(literal)
+		}
+	}
+
+	return shortest
+}
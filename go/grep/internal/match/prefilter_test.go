@@ -0,0 +1,61 @@
+package match
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/ikraduya/codingchallanges/go/grep/internal/normalize"
+)
+
+// A line with a match of the patterns always has a hit of the prefilter
+func TestPrefilterMatchingLines(t *testing.T) {
+	for _, c := range append(append([]regexCase{}, regexCases...), caseModeCases...) {
+		p := NewPrefilter(c.patterns, c.syntax, c.expOptions)
+		if p != nil && c.expected != nil && p.Index([]byte(c.line)) < 0 {
+			t.Errorf("%v: the matching line has no hit", c)
+		}
+	}
+}
+
+func TestPrefilter(t *testing.T) {
+	manyWords := make([]string, 0, 20)
+	for i := range 20 {
+		manyWords = append(manyWords, fmt.Sprintf("w%02d[0-9]+", i))
+	}
+	nfc := ExpressionOption{Normalization: normalize.FormNFC}
+
+	cases := []struct {
+		patterns   []string
+		expOptions ExpressionOption
+		data       string
+		expected   int // -1 when the prefilter has no hit
+	}{
+		{[]string{`foo|bar`}, ExpressionOption{}, "xx bar foo", 3},
+		{[]string{`foo|bar`}, ExpressionOption{}, "xx BAR", -1},
+		{manyWords, ExpressionOption{}, "w1 w05 w19x", 3},
+		{manyWords, ExpressionOption{}, "w1 w20", -1},
+
+		// -i and --normalize: the ASCII literals are folded, any non-ASCII byte is a hit
+		{[]string{`foo|bar`}, insensitive, "xx BAR", 3},
+		{[]string{`foo|bar`}, insensitive, "xx baz", -1},
+		{[]string{`foo|bar`}, insensitive, "xx föo", 4},
+		{manyWords, insensitive, "W1 W05", 3},
+		{[]string{`straße`}, insensitive, "STRASSE", 0},
+		{[]string{`k`}, insensitive, "K", 0},
+		{[]string{`été`}, insensitive, "ete ETE", -1},
+		{[]string{`fo+`, `Bar`}, smartCase, "FOO", 0},
+		{[]string{`café`}, nfc, "cafe", -1},
+		{[]string{`café`}, nfc, "café", 4},
+	}
+
+	for _, c := range cases {
+		p := NewPrefilter(c.patterns, SyntaxExtended, c.expOptions)
+		if p == nil {
+			t.Errorf("%q %+v: no prefilter", c.patterns, c.expOptions)
+			continue
+		}
+		if i := p.Index([]byte(c.data)); i != c.expected {
+			t.Errorf("%q %+v in %q: got %d, expected %d", c.patterns, c.expOptions, c.data, i, c.expected)
+		}
+	}
+}
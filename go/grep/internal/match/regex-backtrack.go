@@ -0,0 +1,254 @@
+package match
+
+import (
+	"errors"
+
+	"github.com/ikraduya/codingchallanges/go/grep/comparisonutils"
+)
+
+// Backtracking simulation of the same program, used for the Perl syntax and for patterns with
+// back references or lookarounds that a Thompson NFA cannot express. It always returns the highest
+// priority (leftmost-first) match.
+//
+// Without back references the future of a thread only depends on its (pc, pos), so a state that was
+// already visited is known to fail and is skipped. That bounds the search to O(len(input) * len(insts)).
+// An input too long for the visited set is searched by the Pike VM in leftmost-first mode instead, unless
+// the pattern needs the backtracker.
+//
+// With back references the search is exponential in the worst case, e.g. "(a|a)*\1b". Like the match
+// limit of PCRE, a search without the visited set gives up after maxBacktrackSteps.
+
+// Upper bound of the visited bitset in bits (4 MiB), longer inputs are searched without it
+const maxBacktrackVisitedBits = 32 * 1024 * 1024
+
+// Upper bound of the instructions run by one find without the visited set, the default match limit of PCRE
+const maxBacktrackSteps = 10_000_000
+
+// Panic value of a match that exceeded maxBacktrackSteps, see CatchBacktrackLimit
+var ErrBacktrackLimit = errors.New("exceeded the backtracking limit")
+
+// Turn the panic of a match that exceeded the backtracking limit into *err. It must be deferred by the
+// callers of a CheckContainsOperation or a Replacer, any other panic goes on.
+func CatchBacktrackLimit(err *error) {
+	if r := recover(); r != nil {
+		if r != ErrBacktrackLimit {
+			panic(r)
+		}
+		*err = ErrBacktrackLimit
+	}
+}
+
+type backtrackJob struct {
+	pc  int
+	pos int
+
+	isRestore bool // restore caps[slot] = pos when popped
+	slot      int
+}
+
+type backtrackMachine struct {
+	prog       *regexProgram
+	hasBackref bool
+	isPikeable bool // no back reference nor lookaround, the Pike VM gives the same matches
+	pike       *pikeMachine
+
+	caps    []int
+	jobs    []backtrackJob
+	visited []uint32
+	steps   int // instructions run by the current find without the visited set
+}
+
+func newBacktrackMachine(prog *regexProgram, hasBackref bool, hasLookaround bool) *backtrackMachine {
+	return &backtrackMachine{
+		prog:       prog,
+		hasBackref: hasBackref,
+		isPikeable: !hasBackref && !hasLookaround,
+		caps:       make([]int, prog.numSlots),
+	}
+}
+
+// Mark (pc, pos) as visited, return false if it already was
+func (m *backtrackMachine) visit(pc int, pos int, inputLen int) bool {
+	n := pc*(inputLen+1) + pos
+	word, bit := n/32, uint32(1)<<(n%32)
+	if m.visited[word]&bit != 0 {
+		return false
+	}
+	m.visited[word] |= bit
+	return true
+}
+
+// Clear the visited set for a new input, return false if it cannot be used
+func (m *backtrackMachine) resetVisited(inputLen int) bool {
+	bits := len(m.prog.insts) * (inputLen + 1)
+	if m.hasBackref || bits > maxBacktrackVisitedBits {
+		return false
+	}
+
+	words := (bits + 31) / 32
+	if cap(m.visited) < words {
+		m.visited = make([]uint32, words)
+		return true
+	}
+	m.visited = m.visited[:words]
+	for i := range m.visited {
+		m.visited[i] = 0
+	}
+	return true
+}
+
+func (m *backtrackMachine) find(input []rune, pos int, isAnchored bool) []int {
+	useVisited := m.resetVisited(len(input))
+	if !useVisited && m.isPikeable {
+		if m.pike == nil {
+			m.pike = newPikeMachine(m.prog, false)
+		}
+		return m.pike.find(input, pos, isAnchored)
+	}
+
+	lastStart := len(input)
+	if isAnchored {
+		lastStart = pos
+	}
+	m.steps = 0
+	for start := pos; start <= lastStart; start++ {
+		for i := range m.caps {
+			m.caps[i] = -1
+		}
+		if _, ok := m.run(0, start, input, useVisited); ok {
+			return m.caps
+		}
+	}
+
+	return nil
+}
+
+// Run the program from pc at pos until instMatch or instLookaroundEnd, return the end position.
+// Lookaround bodies are run without the visited set since they are entered from many states.
+func (m *backtrackMachine) run(pc int, pos int, input []rune, useVisited bool) (int, bool) {
+	jobsBase := len(m.jobs)
+	defer func() { m.jobs = m.jobs[:jobsBase] }()
+
+	m.jobs = append(m.jobs, backtrackJob{pc: pc, pos: pos})
+	for len(m.jobs) > jobsBase {
+		job := m.jobs[len(m.jobs)-1]
+		m.jobs = m.jobs[:len(m.jobs)-1]
+
+		if job.isRestore {
+			m.caps[job.slot] = job.pos
+			continue
+		}
+
+		pc, pos := job.pc, job.pos
+	thread:
+		for {
+			if useVisited && !m.visit(pc, pos, len(input)) {
+				break
+			}
+			if !useVisited {
+				m.steps += 1
+				if m.steps > maxBacktrackSteps {
+					panic(ErrBacktrackLimit)
+				}
+			}
+
+			i := &m.prog.insts[pc]
+			switch i.op {
+			case instRune, instAnyNotNewline, instAny, instClass:
+				if pos >= len(input) || !stepRune(i, input[pos]) {
+					break thread
+				}
+				pc, pos = pc+1, pos+1
+			case instSplit:
+				m.jobs = append(m.jobs, backtrackJob{pc: i.y, pos: pos})
+				pc = i.x
+			case instJump:
+				pc = i.x
+			case instSave:
+				m.jobs = append(m.jobs, backtrackJob{isRestore: true, slot: i.slot, pos: m.caps[i.slot]})
+				m.caps[i.slot] = pos
+				pc += 1
+			case instProgress:
+				if m.caps[i.slot] == pos {
+					pc = i.x
+				} else {
+					pc += 1
+				}
+			case instAssert:
+				if !isAssertionTrue(i.assert, input, pos) {
+					break thread
+				}
+				pc += 1
+			case instBackref:
+				stop, ok := m.matchBackref(i, input, pos)
+				if !ok {
+					break thread
+				}
+				pc, pos = pc+1, stop
+			case instLookaround:
+				if !m.lookaround(i, input, pos) {
+					break thread
+				}
+				pc = i.y
+			case instLookaroundEnd, instMatch:
+				return pos, true
+			}
+		}
+	}
+
+	return 0, false
+}
+
+func (m *backtrackMachine) matchBackref(i *inst, input []rune, pos int) (int, bool) {
+	start, stop := m.caps[2*i.slot], m.caps[2*i.slot+1]
+	if start < 0 || stop < 0 {
+		return 0, false // the group did not participate in the match
+	}
+
+	n := stop - start
+	if pos+n > len(input) {
+		return 0, false
+	}
+	runeEquals := comparisonutils.AreRunesCaseSensitiveEqual
+	if i.isCaseInsensitive {
+		runeEquals = comparisonutils.AreRunesCaseInsensitiveEqual
+	}
+	for k := 0; k < n; k++ {
+		if !runeEquals(input[pos+k], input[start+k]) {
+			return 0, false
+		}
+	}
+
+	return pos + n, true
+}
+
+func (m *backtrackMachine) lookaround(i *inst, input []rune, pos int) bool {
+	bodyStart := pos
+	if i.isLookBehind {
+		bodyStart = pos - i.lookWidth
+		if bodyStart < 0 {
+			return i.isNegated
+		}
+	}
+
+	savedCaps := make([]int, len(m.caps))
+	copy(savedCaps, m.caps)
+
+	stop, ok := m.run(i.x, bodyStart, input, false)
+	if ok && i.isLookBehind && stop != pos {
+		ok = false
+	}
+
+	if ok == i.isNegated || i.isNegated {
+		copy(m.caps, savedCaps)
+		return ok != i.isNegated
+	}
+
+	// a positive lookaround keeps its captures, they are undone like any other save when backtracking
+	for slot, old := range savedCaps {
+		if m.caps[slot] != old {
+			m.jobs = append(m.jobs, backtrackJob{isRestore: true, slot: slot, pos: old})
+		}
+	}
+	return true
+}
@@ -0,0 +1,233 @@
+package match
+
+import "github.com/ikraduya/codingchallanges/go/grep/comparisonutils"
+
+type instOp uint8
+
+const (
+	instRune instOp = iota
+	instAnyNotNewline
+	instAny // "." with IsDotAll
+	instClass
+	instSplit
+	instJump
+	instSave
+	instAssert
+	instBackref
+	instLookaround
+	instLookaroundEnd
+	instProgress
+	instMatch
+)
+
+// A program instruction. Except for instSplit, instJump, instLookaround and instProgress (that leaves a loop when
+// its slot holds the current position), execution continues at the next instruction.
+type inst struct {
+	op     instOp
+	r      rune // folded when isCaseInsensitive
+	class  *charClass
+	assert assertKind
+	x, y   int // instSplit (x has the priority), instJump and instProgress (x only), instLookaround (body, continuation)
+	slot   int // instSave and instProgress, the capture index for instBackref
+
+	lookWidth    int  // instLookaround
+	isLookBehind bool // instLookaround
+	isNegated    bool // instLookaround
+
+	isCaseInsensitive bool // instRune, instClass and instBackref
+}
+
+type regexProgram struct {
+	insts    []inst
+	numSlots int // two slots per capture group, group 0 is the whole match, then one per loop that can match empty
+}
+
+// Upper bound of the compiled program size, guards against patterns like "(a{1000}){1000}"
+const maxProgramSize = 1 << 20
+
+type regexCompiler struct {
+	insts             []inst
+	numSlots          int
+	err               error
+	isCaseInsensitive bool
+	isDotAll          bool
+	isMultiline       bool
+	isNullData        bool
+}
+
+func compileRegexProgram(node *regexNode, captureCount int, expOptions ExpressionOption) (*regexProgram, error) {
+	c := regexCompiler{
+		numSlots:          2 * (captureCount + 1),
+		isCaseInsensitive: expOptions.IsCaseInsensitive,
+		isDotAll:          expOptions.IsDotAll,
+		isMultiline:       expOptions.IsMultiline,
+		isNullData:        expOptions.IsNullData,
+	}
+
+	c.emit(inst{op: instSave, slot: 0})
+	c.compile(node)
+	c.emit(inst{op: instSave, slot: 1})
+	c.emit(inst{op: instMatch})
+
+	if c.err != nil {
+		return nil, c.err
+	}
+
+	return &regexProgram{insts: c.insts, numSlots: c.numSlots}, nil
+}
+
+func (c *regexCompiler) emit(i inst) int {
+	if len(c.insts) >= maxProgramSize {
+		c.err = &RegexSyntaxError{Message: "Regular expression too big"}
+		return len(c.insts) - 1
+	}
+
+	c.insts = append(c.insts, i)
+	return len(c.insts) - 1
+}
+
+func (c *regexCompiler) pc() int {
+	return len(c.insts)
+}
+
+// "^" and "$" are the ends of the input when it is one line, a newline in it (with -z) is then an ordinary
+// rune; with IsMultiline they also match next to a newline, and next to a NUL with IsNullData
+func (c *regexCompiler) lineAssert(assert assertKind) assertKind {
+	switch {
+	case !c.isMultiline && assert == assertBeginLine:
+		return assertBeginText
+	case !c.isMultiline && assert == assertEndLine:
+		return assertEndText
+	case c.isNullData && assert == assertBeginLine:
+		return assertBeginNullLine
+	case c.isNullData && assert == assertEndLine:
+		return assertEndNullLine
+	}
+
+	return assert
+}
+
+func (c *regexCompiler) compile(node *regexNode) {
+	if c.err != nil {
+		return
+	}
+
+	isCaseInsensitive := c.isCaseInsensitive || node.isCaseInsensitive
+	switch node.kind {
+	case nodeEmpty:
+	case nodeLiteral:
+		r := node.r
+		if isCaseInsensitive {
+			r = comparisonutils.FoldRuneCaseInsensitive(r)
+		}
+		c.emit(inst{op: instRune, r: r, isCaseInsensitive: isCaseInsensitive})
+	case nodeAnyChar:
+		if c.isDotAll {
+			c.emit(inst{op: instAny})
+			break
+		}
+		c.emit(inst{op: instAnyNotNewline})
+	case nodeCharClass:
+		c.emit(inst{op: instClass, class: node.class, isCaseInsensitive: isCaseInsensitive})
+	case nodeAssert:
+		c.emit(inst{op: instAssert, assert: c.lineAssert(node.assert)})
+	case nodeConcat:
+		for _, child := range node.children {
+			c.compile(child)
+		}
+	case nodeAlternate:
+		c.compileAlternate(node.children)
+	case nodeCapture:
+		c.emit(inst{op: instSave, slot: 2 * node.captureIndex})
+		c.compile(node.children[0])
+		c.emit(inst{op: instSave, slot: 2*node.captureIndex + 1})
+	case nodeRepeat:
+		c.compileRepeat(node)
+	case nodeBackref:
+		c.emit(inst{op: instBackref, slot: node.captureIndex, isCaseInsensitive: isCaseInsensitive})
+	case nodeLookaround:
+		// the body is a subprogram run by the backtracker, the main path jumps over it
+		lookWidth, _ := fixedWidth(node.children[0])
+		lookaround := c.emit(inst{op: instLookaround, lookWidth: lookWidth, isLookBehind: node.isLookBehind, isNegated: node.isNegated})
+		c.compile(node.children[0])
+		c.emit(inst{op: instLookaroundEnd})
+		if c.err == nil {
+			c.insts[lookaround].x, c.insts[lookaround].y = lookaround+1, c.pc()
+		}
+	}
+}
+
+func (c *regexCompiler) compileAlternate(branches []*regexNode) {
+	jumps := make([]int, 0, len(branches)-1)
+	for i, branch := range branches {
+		if i == len(branches)-1 {
+			c.compile(branch)
+			break
+		}
+
+		split := c.emit(inst{op: instSplit})
+		c.insts[split].x = c.pc()
+		c.compile(branch)
+		jumps = append(jumps, c.emit(inst{op: instJump}))
+		c.insts[split].y = c.pc()
+	}
+
+	for _, jump := range jumps {
+		c.insts[jump].x = c.pc()
+	}
+}
+
+// Set the targets of a split, the preferred one depends on the greediness
+func (c *regexCompiler) patchSplit(split int, body int, out int, greedy bool) {
+	if c.err != nil {
+		return
+	}
+
+	if greedy {
+		c.insts[split].x, c.insts[split].y = body, out
+	} else {
+		c.insts[split].x, c.insts[split].y = out, body
+	}
+}
+
+func (c *regexCompiler) compileRepeat(node *regexNode) {
+	child := node.children[0]
+
+	for i := 0; i < node.min; i++ {
+		c.compile(child)
+	}
+
+	if node.max == repeatInfinite {
+		// L: split body, out; body; jmp L; out:
+		loop := c.emit(inst{op: instSplit})
+		if minWidth(child) > 0 {
+			c.compile(child)
+			c.emit(inst{op: instJump, x: loop})
+			c.patchSplit(loop, loop+1, c.pc(), node.greedy)
+			return
+		}
+
+		// an iteration that matches nothing would repeat forever, it ends the loop like in Perl
+		slot := c.numSlots
+		c.numSlots += 1
+		c.emit(inst{op: instSave, slot: slot})
+		c.compile(child)
+		progress := c.emit(inst{op: instProgress, slot: slot})
+		c.emit(inst{op: instJump, x: loop})
+		c.patchSplit(loop, loop+1, c.pc(), node.greedy)
+		if c.err == nil {
+			c.insts[progress].x = c.pc()
+		}
+		return
+	}
+
+	// every optional copy is nested in the previous one: (x(x(x)?)?)?
+	splits := make([]int, 0, node.max-node.min)
+	for i := node.min; i < node.max; i++ {
+		splits = append(splits, c.emit(inst{op: instSplit}))
+		c.compile(child)
+	}
+	for _, split := range splits {
+		c.patchSplit(split, split+1, c.pc(), node.greedy)
+	}
+}
@@ -0,0 +1,421 @@
+package match
+
+import "slices"
+
+// Lazy DFA of a program without back references nor lookarounds. A state is the set of instructions the
+// threads of the Pike VM would be at, without their captures, so a rune costs one transition once the states
+// it goes through were built. The states are built on the fly and dropped when they take too much memory.
+//
+// The empty transitions of a state are only followed when the next rune is known, so that every assertion
+// can be decided from the flags of the previous rune and the next rune. Whether they reach instMatch is a
+// flag of the next state, the DFA goes on after a match to find all of them.
+//
+// The DFA of the program tells whether a line matches, the DFA of the reversed program (see reverseRegexNode)
+// run from the end of the line tells where every match starts. The Pike VM and the backtracker then only run
+// from the start of each match, and with the leftmost-longest semantics the DFA anchored at the start finds
+// the end of the match when the groups are not needed.
+
+type dfaFlags uint8
+
+const (
+	dfaAtStart dfaFlags = 1 << iota
+	dfaAfterWord
+	dfaAfterNewline
+	dfaAfterNull
+	dfaAfterMatch // instMatch was reached before the previous rune
+	dfaAnchored   // the matches started at the start state, the start of the program is not in the state
+)
+
+// The flags the empty transitions depend on
+const dfaContextFlags = dfaAtStart | dfaAfterWord | dfaAfterNewline | dfaAfterNull
+
+// Unless dfaAnchored, a state also holds the start of the program, where a match may start at any rune
+type dfaState struct {
+	pcs   []int // the instructions reached by consuming the previous rune
+	flags dfaFlags
+
+	asciiNext []*dfaState // by the class of the rune
+	next      map[rune]*dfaState
+	endMatch  int8 // 1 when instMatch is reached at the end of the input, -1 when not, 0 until known
+}
+
+// The instructions reached from the start of the program by consuming a rune, the same for every state with
+// the same flags
+type dfaStartEdge struct {
+	pcs       []int
+	isMatched bool
+}
+
+// Upper bound of the memory taken by the states kept at once
+const maxDFASize = 8 << 20
+
+// The ASCII runes that no instruction, assertion nor flag tells apart share a class, so that a state has one
+// transition per class instead of one per rune
+type dfaClasses struct {
+	classes [0x80]uint8
+	count   int
+}
+
+func newDFAClasses(prog *regexProgram) *dfaClasses {
+	masks := make(map[[2]uint64]bool)
+	addMask := func(isAccepted func(r rune) bool) {
+		var mask [2]uint64
+		for r := rune(0); r < 0x80; r++ {
+			if isAccepted(r) {
+				mask[r/64] |= 1 << (r % 64)
+			}
+		}
+		masks[mask] = true
+	}
+
+	addMask(isWordRune)
+	addMask(func(r rune) bool { return r == '\n' })
+	addMask(func(r rune) bool { return r == 0 })
+	seen := make(map[inst]bool) // the lists of expressions repeat the same runes
+	for _, i := range prog.insts {
+		switch i.op {
+		case instRune, instClass:
+			if !seen[i] {
+				seen[i] = true
+				addMask(func(r rune) bool { return stepRune(&i, r) })
+			}
+		}
+	}
+
+	// split the classes by every mask
+	c := &dfaClasses{count: 1}
+	for mask := range masks {
+		split := make(map[[2]int]uint8)
+		for r := range c.classes {
+			bit := int(mask[r/64] >> (r % 64) & 1)
+			key := [2]int{int(c.classes[r]), bit}
+			class, ok := split[key]
+			if !ok {
+				class = uint8(len(split))
+				split[key] = class
+			}
+			c.classes[r] = class
+		}
+		c.count = len(split)
+	}
+
+	return c
+}
+
+type lazyDFA struct {
+	prog      *regexProgram
+	classes   *dfaClasses
+	states    map[string]*dfaState
+	size      int             // memory taken by states, roughly
+	startNext []*dfaStartEdge // by the flags and the class of an ASCII rune
+
+	// scratch space of the transitions
+	visited    []uint32 // visited[pc] == generation when pc was visited by the current transition
+	generation uint32
+	stack      []int
+	pcs        []int
+	key        []byte
+}
+
+func newLazyDFA(prog *regexProgram, classes *dfaClasses) *lazyDFA {
+	return &lazyDFA{
+		prog:      prog,
+		classes:   classes,
+		states:    make(map[string]*dfaState),
+		startNext: make([]*dfaStartEdge, int(dfaContextFlags+1)*classes.count),
+		visited:   make([]uint32, len(prog.insts)),
+	}
+}
+
+// Flags of the position after r
+func runeFlags(r rune) dfaFlags {
+	switch {
+	case isWordRune(r):
+		return dfaAfterWord
+	case r == '\n':
+		return dfaAfterNewline
+	case r == 0:
+		return dfaAfterNull
+	}
+
+	return 0
+}
+
+// Return the state where a match may only start, with dfaAnchored no other match starts later
+func (d *lazyDFA) start(flags dfaFlags) *dfaState {
+	d.pcs = d.pcs[:0]
+	if flags&dfaAnchored != 0 {
+		d.pcs = append(d.pcs, 0)
+	}
+	return d.state(flags)
+}
+
+// Return true when input has a match
+func (d *lazyDFA) match(input []rune) bool {
+	state := d.start(dfaAtStart)
+	for _, r := range input {
+		state = d.transition(state, r)
+		if state.flags&dfaAfterMatch != 0 {
+			return true
+		}
+	}
+
+	return d.isMatchedAtEnd(state)
+}
+
+// Return the end of the longest match of input that starts at start, or -1
+func (d *lazyDFA) longestMatchEnd(input []rune, start int) int {
+	flags := dfaAtStart
+	if start > 0 {
+		flags = runeFlags(input[start-1])
+	}
+
+	end := -1
+	state := d.start(flags | dfaAnchored)
+	for i := start; i < len(input); i++ {
+		state = d.transition(state, input[i])
+		if state.flags&dfaAfterMatch != 0 {
+			end = i
+		}
+		if len(state.pcs) == 0 { // no thread is left
+			return end
+		}
+	}
+	if d.isMatchedAtEnd(state) {
+		end = len(input)
+	}
+
+	return end
+}
+
+// Run the DFA of the reversed program from the end of input, and set isStart[i] when a match of the program
+// starts at i. isStart has len(input)+1 elements.
+func (d *lazyDFA) matchStarts(input []rune, isStart []bool) {
+	state := d.start(dfaAtStart)
+	for i := len(input); i > 0; i-- {
+		state = d.transition(state, input[i-1])
+		isStart[i] = state.flags&dfaAfterMatch != 0
+	}
+	isStart[0] = d.isMatchedAtEnd(state)
+}
+
+// Return the state of the instructions in d.pcs
+func (d *lazyDFA) state(flags dfaFlags) *dfaState {
+	slices.Sort(d.pcs)
+	d.pcs = slices.Compact(d.pcs)
+
+	d.key = append(d.key[:0], byte(flags))
+	for _, pc := range d.pcs {
+		d.key = append(d.key, byte(pc), byte(pc>>8), byte(pc>>16))
+	}
+	if state, ok := d.states[string(d.key)]; ok {
+		return state
+	}
+
+	size := 2*len(d.key) + 8*len(d.pcs) + 8*d.classes.count + 128
+	if d.size+size > maxDFASize {
+		clear(d.states) // the states in use keep their transitions
+		d.size = 0
+	}
+	d.size += size
+	state := &dfaState{pcs: slices.Clone(d.pcs), flags: flags, asciiNext: make([]*dfaState, d.classes.count)}
+	d.states[string(d.key)] = state
+	return state
+}
+
+// Follow the empty transitions of pcs before r, or before the end of the input when isEnd, and append the
+// instructions reached by consuming r to d.pcs. Return true when instMatch is reached.
+func (d *lazyDFA) closure(pcs []int, flags dfaFlags, r rune, isEnd bool) bool {
+	d.generation += 1
+	if d.generation == 0 {
+		clear(d.visited)
+		d.generation = 1
+	}
+
+	d.stack = append(d.stack[:0], pcs...)
+	isMatched := false
+	for len(d.stack) > 0 {
+		pc := d.stack[len(d.stack)-1]
+		d.stack = d.stack[:len(d.stack)-1]
+		if d.visited[pc] == d.generation {
+			continue
+		}
+		d.visited[pc] = d.generation
+
+		i := &d.prog.insts[pc]
+		switch i.op {
+		case instJump:
+			d.stack = append(d.stack, i.x)
+		case instSplit:
+			d.stack = append(d.stack, i.x, i.y)
+		case instProgress: // whether an iteration matched empty does not change what can match after the loop
+			d.stack = append(d.stack, i.x, pc+1)
+		case instSave:
+			d.stack = append(d.stack, pc+1)
+		case instAssert:
+			if isDFAAssertionTrue(i.assert, flags, r, isEnd) {
+				d.stack = append(d.stack, pc+1)
+			}
+		case instMatch:
+			isMatched = true
+		default:
+			if !isEnd && stepRune(i, r) {
+				d.pcs = append(d.pcs, pc+1)
+			}
+		}
+	}
+
+	return isMatched
+}
+
+// Return true when the empty transitions of state reach instMatch at the end of the input
+func (d *lazyDFA) isMatchedAtEnd(state *dfaState) bool {
+	if state.endMatch == 0 {
+		state.endMatch = -1
+		d.pcs = d.pcs[:0]
+		isAnchored := state.flags&dfaAnchored != 0
+		if d.closure(state.pcs, state.flags, 0, true) || (!isAnchored && d.closure([]int{0}, state.flags, 0, true)) {
+			state.endMatch = 1
+		}
+	}
+
+	return state.endMatch > 0
+}
+
+// Append the instructions reached from the start of the program by consuming r after a rune of flags to
+// d.pcs, return true when instMatch is reached before r. The ASCII runes are cached, the start of a list of
+// expressions is a long chain of splits.
+func (d *lazyDFA) startTransition(flags dfaFlags, r rune) bool {
+	if r >= 0x80 {
+		return d.closure([]int{0}, flags, r, false)
+	}
+
+	i := int(flags&dfaContextFlags)*d.classes.count + int(d.classes.classes[r])
+	edge := d.startNext[i]
+	if edge == nil {
+		n := len(d.pcs)
+		isMatched := d.closure([]int{0}, flags, r, false)
+		edge = &dfaStartEdge{pcs: slices.Clone(d.pcs[n:]), isMatched: isMatched}
+		d.startNext[i] = edge
+		d.pcs = d.pcs[:n]
+	}
+	d.pcs = append(d.pcs, edge.pcs...)
+	return edge.isMatched
+}
+
+// Return the state after consuming r from state
+func (d *lazyDFA) transition(state *dfaState, r rune) *dfaState {
+	if r < 0x80 {
+		if next := state.asciiNext[d.classes.classes[r]]; next != nil {
+			return next
+		}
+	} else if next, ok := state.next[r]; ok {
+		return next
+	}
+
+	d.pcs = d.pcs[:0]
+	isMatched := d.closure(state.pcs, state.flags, r, false)
+	if state.flags&dfaAnchored == 0 && d.startTransition(state.flags, r) {
+		isMatched = true
+	}
+	flags := runeFlags(r) | state.flags&dfaAnchored
+	if isMatched {
+		flags |= dfaAfterMatch
+	}
+	next := d.state(flags)
+
+	if r < 0x80 {
+		state.asciiNext[d.classes.classes[r]] = next
+	} else {
+		if state.next == nil {
+			state.next = make(map[rune]*dfaState)
+		}
+		state.next[r] = next
+	}
+	return next
+}
+
+// Like isAssertionTrue, with the previous rune known from flags and next the next rune unless isEnd
+func isDFAAssertionTrue(assert assertKind, flags dfaFlags, next rune, isEnd bool) bool {
+	prevIsWord := flags&dfaAfterWord != 0
+	nextIsWord := !isEnd && isWordRune(next)
+	isBeginText := flags&dfaAtStart != 0
+
+	switch assert {
+	case assertBeginLine:
+		return isBeginText || flags&dfaAfterNewline != 0
+	case assertEndLine:
+		return isEnd || next == '\n'
+	case assertWordBoundary:
+		return prevIsWord != nextIsWord
+	case assertNotWordBoundary:
+		return prevIsWord == nextIsWord
+	case assertBeginWord:
+		return !prevIsWord && nextIsWord
+	case assertEndWord:
+		return prevIsWord && !nextIsWord
+	case assertBeginText:
+		return isBeginText
+	case assertEndText:
+		return isEnd
+	case assertNotAfterWord:
+		return !prevIsWord
+	case assertNotBeforeWord:
+		return !nextIsWord
+	case assertBeginNullLine:
+		return isBeginText || flags&(dfaAfterNewline|dfaAfterNull) != 0
+	case assertEndNullLine:
+		return isEnd || next == '\n' || next == 0
+	}
+
+	return false
+}
+
+// Return the tree matching the reversed strings of node, the assertions on the previous rune become the ones
+// on the next rune. The tree has no back reference nor lookaround.
+func reverseRegexNode(node *regexNode) *regexNode {
+	reversed := *node
+	switch node.kind {
+	case nodeAssert:
+		reversed.assert = reverseAssert(node.assert)
+	case nodeConcat:
+		reversed.children = make([]*regexNode, len(node.children))
+		for i, child := range node.children {
+			reversed.children[len(node.children)-1-i] = reverseRegexNode(child)
+		}
+	case nodeAlternate, nodeRepeat, nodeCapture:
+		reversed.children = make([]*regexNode, len(node.children))
+		for i, child := range node.children {
+			reversed.children[i] = reverseRegexNode(child)
+		}
+	}
+
+	return &reversed
+}
+
+func reverseAssert(assert assertKind) assertKind {
+	switch assert {
+	case assertBeginLine:
+		return assertEndLine
+	case assertEndLine:
+		return assertBeginLine
+	case assertBeginWord:
+		return assertEndWord
+	case assertEndWord:
+		return assertBeginWord
+	case assertBeginText:
+		return assertEndText
+	case assertEndText:
+		return assertBeginText
+	case assertNotAfterWord:
+		return assertNotBeforeWord
+	case assertNotBeforeWord:
+		return assertNotAfterWord
+	case assertBeginNullLine:
+		return assertEndNullLine
+	case assertEndNullLine:
+		return assertBeginNullLine
+	}
+
+	return assert // the word boundaries look both ways
+}
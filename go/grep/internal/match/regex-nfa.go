@@ -0,0 +1,216 @@
+package match
+
+import "github.com/ikraduya/codingchallanges/go/grep/comparisonutils"
+
+// Thompson NFA simulation with capture tracking (Pike VM). Every thread advances one rune at a time
+// and at most one thread per instruction is alive, so the running time is O(len(input) * len(insts)).
+
+type pikeThread struct {
+	pc   int
+	caps []int
+}
+
+type pikeQueue struct {
+	sparse []int
+	dense  []pikeThread
+}
+
+func newPikeQueue(size int, numSlots int) *pikeQueue {
+	q := &pikeQueue{sparse: make([]int, size), dense: make([]pikeThread, 0, size)}
+	for i := 0; i < size; i++ {
+		q.dense = append(q.dense, pikeThread{caps: make([]int, numSlots)})
+	}
+	q.dense = q.dense[:0]
+
+	return q
+}
+
+func (q *pikeQueue) contains(pc int) bool {
+	i := q.sparse[pc]
+	return i < len(q.dense) && q.dense[i].pc == pc
+}
+
+// Insert pc and return the slot that holds its thread
+func (q *pikeQueue) insert(pc int) *pikeThread {
+	n := len(q.dense)
+	q.sparse[pc] = n
+	q.dense = q.dense[:n+1]
+	q.dense[n].pc = pc
+
+	return &q.dense[n]
+}
+
+func (q *pikeQueue) clear() {
+	q.dense = q.dense[:0]
+}
+
+type pikeMachine struct {
+	prog      *regexProgram
+	isLongest bool
+	clist     *pikeQueue
+	nlist     *pikeQueue
+	scratch   []int
+	matchCaps []int
+}
+
+func newPikeMachine(prog *regexProgram, isLongest bool) *pikeMachine {
+	return &pikeMachine{
+		prog:      prog,
+		isLongest: isLongest,
+		clist:     newPikeQueue(len(prog.insts), prog.numSlots),
+		nlist:     newPikeQueue(len(prog.insts), prog.numSlots),
+		scratch:   make([]int, prog.numSlots),
+		matchCaps: make([]int, prog.numSlots),
+	}
+}
+
+func isAssertionTrue(assert assertKind, input []rune, pos int) bool {
+	prevIsWord := pos > 0 && isWordRune(input[pos-1])
+	nextIsWord := pos < len(input) && isWordRune(input[pos])
+
+	switch assert {
+	case assertBeginLine:
+		return pos == 0 || input[pos-1] == '\n'
+	case assertEndLine:
+		return pos == len(input) || input[pos] == '\n'
+	case assertWordBoundary:
+		return prevIsWord != nextIsWord
+	case assertNotWordBoundary:
+		return prevIsWord == nextIsWord
+	case assertBeginWord:
+		return !prevIsWord && nextIsWord
+	case assertEndWord:
+		return prevIsWord && !nextIsWord
+	case assertBeginText:
+		return pos == 0
+	case assertEndText:
+		return pos == len(input)
+	case assertNotAfterWord:
+		return !prevIsWord
+	case assertNotBeforeWord:
+		return !nextIsWord
+	case assertBeginNullLine:
+		return pos == 0 || input[pos-1] == '\n' || input[pos-1] == 0
+	case assertEndNullLine:
+		return pos == len(input) || input[pos] == '\n' || input[pos] == 0
+	}
+
+	return false
+}
+
+// Follow the empty transitions from pc and add every reachable consuming instruction to q
+func (m *pikeMachine) addThread(q *pikeQueue, pc int, input []rune, pos int, caps []int) {
+	if q.contains(pc) {
+		return
+	}
+
+	i := &m.prog.insts[pc]
+	switch i.op {
+	case instJump:
+		q.insert(pc)
+		m.addThread(q, i.x, input, pos, caps)
+	case instSplit:
+		q.insert(pc)
+		m.addThread(q, i.x, input, pos, caps)
+		m.addThread(q, i.y, input, pos, caps)
+	case instAssert:
+		q.insert(pc)
+		if isAssertionTrue(i.assert, input, pos) {
+			m.addThread(q, pc+1, input, pos, caps)
+		}
+	case instProgress:
+		q.insert(pc)
+		if caps[i.slot] == pos {
+			m.addThread(q, i.x, input, pos, caps)
+		} else {
+			m.addThread(q, pc+1, input, pos, caps)
+		}
+	case instSave:
+		q.insert(pc)
+		old := caps[i.slot]
+		caps[i.slot] = pos
+		m.addThread(q, pc+1, input, pos, caps)
+		caps[i.slot] = old
+	default: // consuming instruction or match, the thread waits here
+		t := q.insert(pc)
+		copy(t.caps, caps)
+	}
+}
+
+func stepRune(i *inst, r rune) bool {
+	switch i.op {
+	case instRune:
+		if i.isCaseInsensitive {
+			return comparisonutils.FoldRuneCaseInsensitive(r) == i.r
+		}
+		return r == i.r
+	case instAnyNotNewline:
+		return r != '\n'
+	case instAny:
+		return true
+	case instClass:
+		return i.class.matches(r, i.isCaseInsensitive)
+	}
+
+	return false
+}
+
+// Find the first match in input starting at or after pos, or at pos when isAnchored. With isLongest, the
+// longest of the leftmost matches wins (POSIX), otherwise the highest priority thread wins (Perl).
+// Returns the capture slots of the match, or nil.
+func (m *pikeMachine) find(input []rune, pos int, isAnchored bool) []int {
+	m.clist.clear()
+	m.nlist.clear()
+
+	isMatched := false
+	for start := pos; ; pos++ {
+		if !isMatched && (!isAnchored || pos == start) {
+			for i := range m.scratch {
+				m.scratch[i] = -1
+			}
+			m.addThread(m.clist, 0, input, pos, m.scratch)
+		}
+		if len(m.clist.dense) == 0 {
+			break
+		}
+
+		for ti := 0; ti < len(m.clist.dense); ti++ {
+			t := &m.clist.dense[ti]
+			i := &m.prog.insts[t.pc]
+
+			if i.op == instMatch {
+				if m.isLongest {
+					if !isMatched || t.caps[0] < m.matchCaps[0] || (t.caps[0] == m.matchCaps[0] && t.caps[1] > m.matchCaps[1]) {
+						copy(m.matchCaps, t.caps)
+					}
+					isMatched = true
+					continue
+				}
+
+				copy(m.matchCaps, t.caps)
+				isMatched = true
+				break // lower priority threads are cut off
+			}
+
+			if isMatched && m.isLongest && t.caps[0] > m.matchCaps[0] {
+				continue // started to the right of the leftmost match
+			}
+
+			if pos < len(input) && stepRune(i, input[pos]) {
+				m.addThread(m.nlist, t.pc+1, input, pos+1, t.caps)
+			}
+		}
+
+		m.clist, m.nlist = m.nlist, m.clist
+		m.nlist.clear()
+
+		if pos >= len(input) {
+			break
+		}
+	}
+
+	if !isMatched {
+		return nil
+	}
+	return m.matchCaps
+}
@@ -0,0 +1,976 @@
+package match
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type regexNodeKind int
+
+const (
+	nodeEmpty regexNodeKind = iota
+	nodeLiteral
+	nodeAnyChar
+	nodeCharClass
+	nodeAssert
+	nodeConcat
+	nodeAlternate
+	nodeRepeat
+	nodeCapture
+	nodeGroup // non-capturing, only exists while parsing
+	nodeBackref
+	nodeLookaround
+)
+
+type assertKind int
+
+const (
+	assertBeginLine assertKind = iota
+	assertEndLine
+	assertWordBoundary
+	assertNotWordBoundary
+	assertBeginWord
+	assertEndWord
+	assertBeginText
+	assertEndText
+	assertNotAfterWord  // -w, the previous rune is not a word rune
+	assertNotBeforeWord // -w, the next rune is not a word rune
+	assertBeginNullLine // "^" of -U -z, after a newline or a NUL
+	assertEndNullLine   // "$" of -U -z, before a newline or a NUL
+)
+
+// Infinite upper bound of a repetition
+const repeatInfinite = -1
+
+type regexNode struct {
+	kind regexNodeKind
+
+	r      rune       // nodeLiteral
+	class  *charClass // nodeCharClass
+	assert assertKind // nodeAssert
+
+	children []*regexNode // nodeConcat, nodeAlternate, nodeRepeat, nodeCapture and nodeLookaround (single child)
+
+	min, max int  // nodeRepeat
+	greedy   bool // nodeRepeat
+
+	captureIndex int // nodeCapture and nodeBackref
+
+	isLookBehind bool // nodeLookaround
+	isNegated    bool // nodeLookaround
+
+	isCaseInsensitive bool // nodeLiteral, nodeCharClass and nodeBackref of a case insensitive pattern of -S
+}
+
+type RegexSyntaxError struct {
+	Pattern string
+	Message string
+}
+
+func (err *RegexSyntaxError) Error() string {
+	return fmt.Sprintf("%s in pattern %q", err.Message, err.Pattern)
+}
+
+type RegexSyntax int
+
+const (
+	SyntaxBasic RegexSyntax = iota
+	SyntaxExtended
+	SyntaxPerl
+	SyntaxFixed // every rune is a literal
+)
+
+type regexOperator int
+
+const (
+	operatorGroupOpen regexOperator = iota
+	operatorGroupClose
+	operatorAlternate
+	operatorPlus
+	operatorQuestion
+	operatorIntervalOpen
+	operatorIntervalClose
+)
+
+// In POSIX basic syntax most operators are escaped and their plain characters are literals
+var basicOperators = map[regexOperator]string{
+	operatorGroupOpen:     `\(`,
+	operatorGroupClose:    `\)`,
+	operatorAlternate:     `\|`,
+	operatorPlus:          `\+`,
+	operatorQuestion:      `\?`,
+	operatorIntervalOpen:  `\{`,
+	operatorIntervalClose: `\}`,
+}
+
+var extendedOperators = map[regexOperator]string{
+	operatorGroupOpen:     "(",
+	operatorGroupClose:    ")",
+	operatorAlternate:     "|",
+	operatorPlus:          "+",
+	operatorQuestion:      "?",
+	operatorIntervalOpen:  "{",
+	operatorIntervalClose: "}",
+}
+
+type regexParser struct {
+	pattern   []rune
+	pos       int
+	syntax    RegexSyntax
+	operators map[regexOperator]string
+
+	captureCount  int
+	captureOffset int // groups of the previous patterns in the list, back references are relative to it
+	captureNames  map[string]int
+	hasBackref    bool
+	hasLookaround bool
+}
+
+type parsedRegex struct {
+	root          *regexNode
+	patternRoots  []*regexNode // the root of each pattern of the list
+	captureCount  int
+	captureNames  map[string]int
+	hasBackref    bool
+	hasLookaround bool
+}
+
+// Parse every pattern as one alternation. Capture groups are numbered across the whole list.
+func parseRegexList(patterns []string, syntax RegexSyntax) (*parsedRegex, error) {
+	p := regexParser{syntax: syntax, operators: extendedOperators, captureNames: make(map[string]int)}
+	if syntax == SyntaxBasic {
+		p.operators = basicOperators
+	}
+
+	branches := make([]*regexNode, 0, len(patterns))
+	for _, pattern := range patterns {
+		p.pattern, p.pos = []rune(pattern), 0
+		p.captureOffset = p.captureCount
+
+		if syntax == SyntaxFixed {
+			branches = append(branches, literalNode(p.pattern))
+			continue
+		}
+
+		node, err := p.parseAlternate(0)
+		if err != nil {
+			return nil, err
+		}
+		if !p.atEnd() { // only a stray ')' can stop the top level early
+			return nil, p.errorf("Unmatched ) or \\)")
+		}
+		branches = append(branches, node)
+	}
+
+	root := &regexNode{kind: nodeAlternate, children: branches}
+	if len(branches) == 1 {
+		root = branches[0]
+	}
+
+	return &parsedRegex{
+		root:          root,
+		patternRoots:  branches,
+		captureCount:  p.captureCount,
+		captureNames:  p.captureNames,
+		hasBackref:    p.hasBackref,
+		hasLookaround: p.hasLookaround,
+	}, nil
+}
+
+func literalNode(runes []rune) *regexNode {
+	items := make([]*regexNode, 0, len(runes))
+	for _, r := range runes {
+		items = append(items, &regexNode{kind: nodeLiteral, r: r})
+	}
+
+	switch len(items) {
+	case 0:
+		return &regexNode{kind: nodeEmpty}
+	case 1:
+		return items[0]
+	default:
+		return &regexNode{kind: nodeConcat, children: items}
+	}
+}
+
+// Return the runes of a node that only matches one literal string
+func literalRunes(node *regexNode) ([]rune, bool) {
+	switch node.kind {
+	case nodeEmpty:
+		return []rune{}, true
+	case nodeLiteral:
+		return []rune{node.r}, true
+	case nodeConcat:
+		runes := make([]rune, 0, len(node.children))
+		for _, child := range node.children {
+			if child.kind != nodeLiteral {
+				return nil, false
+			}
+			runes = append(runes, child.r)
+		}
+		return runes, true
+	}
+
+	return nil, false
+}
+
+func (p *regexParser) errorf(format string, a ...interface{}) error {
+	return &RegexSyntaxError{Pattern: string(p.pattern), Message: fmt.Sprintf(format, a...)}
+}
+
+func (p *regexParser) atEnd() bool {
+	return p.pos >= len(p.pattern)
+}
+
+func (p *regexParser) peek() rune {
+	return p.pattern[p.pos]
+}
+
+func (p *regexParser) peekAt(offset int) (rune, bool) {
+	if p.pos+offset >= len(p.pattern) {
+		return 0, false
+	}
+	return p.pattern[p.pos+offset], true
+}
+
+func (p *regexParser) hasPrefix(prefix string) bool {
+	i := p.pos
+	for _, r := range prefix {
+		if i >= len(p.pattern) || p.pattern[i] != r {
+			return false
+		}
+		i += 1
+	}
+	return true
+}
+
+// Report whether the operator starts at the current position, and consume it if so
+func (p *regexParser) consumeOperator(op regexOperator) bool {
+	token := p.operators[op]
+	if !p.hasPrefix(token) {
+		return false
+	}
+	p.pos += len([]rune(token))
+	return true
+}
+
+func (p *regexParser) isAtOperator(op regexOperator) bool {
+	return p.hasPrefix(p.operators[op])
+}
+
+func (p *regexParser) parseAlternate(depth int) (*regexNode, error) {
+	branches := make([]*regexNode, 0, 1)
+	for {
+		branch, err := p.parseConcat(depth)
+		if err != nil {
+			return nil, err
+		}
+		branches = append(branches, branch)
+
+		if !p.consumeOperator(operatorAlternate) {
+			break
+		}
+	}
+
+	if len(branches) == 1 {
+		return branches[0], nil
+	}
+	return &regexNode{kind: nodeAlternate, children: branches}, nil
+}
+
+func (p *regexParser) isAtBranchEnd() bool {
+	return p.atEnd() || p.isAtOperator(operatorAlternate) || p.isAtOperator(operatorGroupClose)
+}
+
+func (p *regexParser) parseConcat(depth int) (*regexNode, error) {
+	items := make([]*regexNode, 0)
+	for !p.atEnd() {
+		if p.isAtOperator(operatorAlternate) {
+			break
+		}
+		if p.isAtOperator(operatorGroupClose) {
+			if depth > 0 {
+				break
+			}
+			if p.syntax != SyntaxExtended { // GNU grep reads an unmatched ')' of an extended expression as a literal
+				return nil, p.errorf("Unmatched ) or \\)")
+			}
+		}
+
+		atom, err := p.parseAtom(depth, len(items) == 0)
+		if err != nil {
+			return nil, err
+		}
+
+		atom, err = p.parseQuantifiers(atom)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, atom)
+	}
+
+	switch len(items) {
+	case 0:
+		return &regexNode{kind: nodeEmpty}, nil
+	case 1:
+		return items[0], nil
+	default:
+		return &regexNode{kind: nodeConcat, children: items}, nil
+	}
+}
+
+func (p *regexParser) parseAtom(depth int, isFirstInBranch bool) (*regexNode, error) {
+	if p.consumeOperator(operatorGroupOpen) {
+		return p.parseGroup(depth)
+	}
+
+	r := p.peek()
+	switch r {
+	case '[':
+		class, err := p.parseBracket()
+		if err != nil {
+			return nil, err
+		}
+		return &regexNode{kind: nodeCharClass, class: class}, nil
+	case '.':
+		p.pos += 1
+		return &regexNode{kind: nodeAnyChar}, nil
+	case '^':
+		// in basic syntax '^' is an anchor only at the beginning of a branch
+		if p.syntax != SyntaxBasic || isFirstInBranch {
+			p.pos += 1
+			return &regexNode{kind: nodeAssert, assert: assertBeginLine}, nil
+		}
+	case '$':
+		// and '$' only at the end of a branch
+		p.pos += 1
+		if p.syntax != SyntaxBasic || p.isAtBranchEnd() {
+			return &regexNode{kind: nodeAssert, assert: assertEndLine}, nil
+		}
+		return &regexNode{kind: nodeLiteral, r: r}, nil
+	case '\\':
+		return p.parseEscape()
+	case '*', '+', '?', '{':
+		if p.syntax == SyntaxPerl && (r != '{' || p.isAtValidInterval()) {
+			return nil, p.errorf("Quantifier does not follow a repeatable item")
+		}
+	}
+
+	// anything else is a literal, including a quantifier with nothing to repeat ("*a")
+	// and a '{' that does not start a valid interval ("a{2")
+	p.pos += 1
+	return &regexNode{kind: nodeLiteral, r: r}, nil
+}
+
+func (p *regexParser) parseGroup(depth int) (*regexNode, error) {
+	node := &regexNode{kind: nodeCapture}
+
+	if p.syntax == SyntaxPerl && p.hasPrefix("?") {
+		switch {
+		case p.hasPrefix("?:"):
+			p.pos += 2
+			node.kind = nodeGroup
+		case p.hasPrefix("?="), p.hasPrefix("?!"):
+			node.kind = nodeLookaround
+			node.isNegated = p.pattern[p.pos+1] == '!'
+			p.pos += 2
+		case p.hasPrefix("?<="), p.hasPrefix("?<!"):
+			node.kind = nodeLookaround
+			node.isLookBehind = true
+			node.isNegated = p.pattern[p.pos+2] == '!'
+			p.pos += 3
+		case p.hasPrefix("?<"), p.hasPrefix("?P<"):
+			if p.hasPrefix("?P<") {
+				p.pos += 1
+			}
+			p.pos += 2
+			nameStart := p.pos
+			for !p.atEnd() && isWordRune(p.peek()) {
+				p.pos += 1
+			}
+			if nameStart == p.pos || p.atEnd() || p.peek() != '>' {
+				return nil, p.errorf("Invalid group name")
+			}
+			name := string(p.pattern[nameStart:p.pos])
+			p.pos += 1
+			if _, isDuplicate := p.captureNames[name]; isDuplicate {
+				return nil, p.errorf("Duplicate group name %q", name)
+			}
+			p.captureNames[name] = p.captureCount + 1
+		default:
+			return nil, p.errorf("Unsupported group syntax")
+		}
+	}
+
+	if node.kind == nodeCapture {
+		p.captureCount += 1
+		node.captureIndex = p.captureCount
+	}
+	if node.kind == nodeLookaround {
+		p.hasLookaround = true
+	}
+
+	inner, err := p.parseAlternate(depth + 1)
+	if err != nil {
+		return nil, err
+	}
+	if !p.consumeOperator(operatorGroupClose) {
+		return nil, p.errorf("Unmatched ( or \\(")
+	}
+	node.children = []*regexNode{inner}
+
+	if node.kind == nodeLookaround && node.isLookBehind {
+		if _, isFixed := fixedWidth(inner); !isFixed {
+			return nil, p.errorf("Lookbehind assertion is not fixed length")
+		}
+	}
+	if node.kind == nodeGroup {
+		return inner, nil
+	}
+
+	return node, nil
+}
+
+func (p *regexParser) parseEscape() (*regexNode, error) {
+	p.pos += 1 // skip '\'
+	if p.atEnd() {
+		return nil, p.errorf("Trailing backslash")
+	}
+
+	r := p.peek()
+	p.pos += 1
+
+	switch r {
+	case 'd', 'D', 'w', 'W', 's', 'S':
+		return &regexNode{kind: nodeCharClass, class: escapeClass(r)}, nil
+	case 'p', 'P':
+		if p.syntax == SyntaxPerl {
+			class, err := p.parsePropertyEscape(r)
+			if err != nil {
+				return nil, err
+			}
+			return &regexNode{kind: nodeCharClass, class: class}, nil
+		}
+	case 'b':
+		return &regexNode{kind: nodeAssert, assert: assertWordBoundary}, nil
+	case 'B':
+		return &regexNode{kind: nodeAssert, assert: assertNotWordBoundary}, nil
+	case '<', '>':
+		if p.syntax != SyntaxPerl {
+			if r == '<' {
+				return &regexNode{kind: nodeAssert, assert: assertBeginWord}, nil
+			}
+			return &regexNode{kind: nodeAssert, assert: assertEndWord}, nil
+		}
+	case '`':
+		if p.syntax != SyntaxPerl {
+			return &regexNode{kind: nodeAssert, assert: assertBeginText}, nil
+		}
+	case '\'':
+		if p.syntax != SyntaxPerl {
+			return &regexNode{kind: nodeAssert, assert: assertEndText}, nil
+		}
+	case 'A':
+		if p.syntax == SyntaxPerl {
+			return &regexNode{kind: nodeAssert, assert: assertBeginText}, nil
+		}
+	case 'z', 'Z':
+		if p.syntax == SyntaxPerl {
+			return &regexNode{kind: nodeAssert, assert: assertEndText}, nil
+		}
+	case '1', '2', '3', '4', '5', '6', '7', '8', '9':
+		captureIndex := p.captureOffset + int(r-'0')
+		if captureIndex > p.captureCount {
+			return nil, p.errorf("Invalid back reference")
+		}
+		p.hasBackref = true
+		return &regexNode{kind: nodeBackref, captureIndex: captureIndex}, nil
+	}
+
+	if lit, ok, err := p.parseLiteralEscape(r); err != nil {
+		return nil, err
+	} else if ok {
+		return &regexNode{kind: nodeLiteral, r: lit}, nil
+	}
+	if err := p.checkUnknownEscape(r); err != nil {
+		return nil, err
+	}
+
+	return &regexNode{kind: nodeLiteral, r: r}, nil
+}
+
+// PCRE reads an escaped letter or digit it does not know as an error rather than a literal,
+// e.g. "\Q...\E" or "\k<name>" that are not supported here
+func (p *regexParser) checkUnknownEscape(r rune) error {
+	if p.syntax == SyntaxPerl && r < utf8.RuneSelf && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+		return p.errorf("Unsupported escape sequence \\%c", r)
+	}
+	return nil
+}
+
+// Parse the name of "\pL", "\p{Greek}" or "\P{^Lu}" as a class of a Unicode category or script,
+// r is 'p' or 'P' which is already consumed
+func (p *regexParser) parsePropertyEscape(r rune) (*charClass, error) {
+	if p.atEnd() {
+		return nil, p.errorf("Malformed \\%c sequence", r)
+	}
+
+	var name string
+	if p.peek() == '{' {
+		nameStop := p.pos + 1
+		for nameStop < len(p.pattern) && p.pattern[nameStop] != '}' {
+			nameStop += 1
+		}
+		if nameStop >= len(p.pattern) {
+			return nil, p.errorf("Malformed \\%c sequence", r)
+		}
+		name = string(p.pattern[p.pos+1 : nameStop])
+		p.pos = nameStop + 1
+	} else {
+		name = string(p.peek())
+		p.pos += 1
+	}
+
+	class := &charClass{negated: r == 'P'}
+	if strings.HasPrefix(name, "^") {
+		name = name[1:]
+		class.negated = !class.negated
+	}
+
+	switch {
+	case name == "Any":
+		class.addRange(0, unicode.MaxRune)
+	case name == "L&":
+		class.tables = []*unicode.RangeTable{unicode.Lu, unicode.Ll, unicode.Lt}
+	case unicode.Categories[name] != nil:
+		class.tables = []*unicode.RangeTable{unicode.Categories[name]}
+	case unicode.Scripts[name] != nil:
+		class.tables = []*unicode.RangeTable{unicode.Scripts[name]}
+	default:
+		return nil, p.errorf("Unknown property name after \\P or \\p")
+	}
+
+	return class, nil
+}
+
+// Escapes that stand for a single rune, r is the rune right after the backslash which is already consumed
+func (p *regexParser) parseLiteralEscape(r rune) (rune, bool, error) {
+	switch r {
+	case 'n':
+		return '\n', true, nil
+	case 't':
+		return '\t', true, nil
+	}
+	if p.syntax != SyntaxPerl {
+		return 0, false, nil
+	}
+
+	switch r {
+	case 'r':
+		return '\r', true, nil
+	case 'f':
+		return '\f', true, nil
+	case 'v':
+		return '\v', true, nil
+	case 'a':
+		return '\a', true, nil
+	case 'e':
+		return 0x1b, true, nil
+	case 'x':
+		return p.parseHexEscape()
+	}
+
+	return 0, false, nil
+}
+
+// Parse the digits of "\xhh" or "\x{h...}"
+func (p *regexParser) parseHexEscape() (rune, bool, error) {
+	digitsStart, digitsStop := p.pos, p.pos
+	if !p.atEnd() && p.peek() == '{' {
+		digitsStart += 1
+		digitsStop = digitsStart
+		for digitsStop < len(p.pattern) && p.pattern[digitsStop] != '}' {
+			digitsStop += 1
+		}
+		if digitsStop >= len(p.pattern) {
+			return 0, false, p.errorf("Missing } in \\x{...}")
+		}
+		p.pos = digitsStop + 1
+	} else {
+		for digitsStop < len(p.pattern) && digitsStop-digitsStart < 2 && unicode.Is(unicode.ASCII_Hex_Digit, p.pattern[digitsStop]) {
+			digitsStop += 1
+		}
+		p.pos = digitsStop
+	}
+
+	if digitsStart == digitsStop {
+		return 0, true, nil
+	}
+	n, err := strconv.ParseUint(string(p.pattern[digitsStart:digitsStop]), 16, 32)
+	if err != nil || n > unicode.MaxRune {
+		return 0, false, p.errorf("Invalid hexadecimal escape")
+	}
+
+	return rune(n), true, nil
+}
+
+func (p *regexParser) parseQuantifiers(atom *regexNode) (*regexNode, error) {
+	if p.syntax == SyntaxBasic && atom.kind == nodeAssert && atom.assert == assertBeginLine {
+		return atom, nil // "^*" matches a leading '*'
+	}
+
+	for !p.atEnd() {
+		min, max := 0, 0
+		switch {
+		case p.hasPrefix("*"):
+			min, max = 0, repeatInfinite
+			p.pos += 1
+		case p.consumeOperator(operatorPlus):
+			min, max = 1, repeatInfinite
+		case p.consumeOperator(operatorQuestion):
+			min, max = 0, 1
+		case p.isAtOperator(operatorIntervalOpen):
+			intervalMin, intervalMax, ok, err := p.parseInterval()
+			if err != nil {
+				return nil, err
+			}
+			if !ok { // not an interval, the '{' is a literal for the next atom
+				return atom, nil
+			}
+			min, max = intervalMin, intervalMax
+		default:
+			return atom, nil
+		}
+
+		greedy := true
+		if p.syntax == SyntaxPerl && !p.atEnd() {
+			switch p.peek() {
+			case '?':
+				greedy = false
+				p.pos += 1
+			case '+':
+				return nil, p.errorf("Possessive quantifiers are not supported")
+			}
+		}
+
+		if atom.kind == nodeAssert || atom.kind == nodeLookaround {
+			continue // a repeated assertion is still just an assertion
+		}
+		atom = &regexNode{kind: nodeRepeat, children: []*regexNode{atom}, min: min, max: max, greedy: greedy}
+	}
+
+	return atom, nil
+}
+
+func (p *regexParser) isAtValidInterval() bool {
+	start := p.pos
+	_, _, ok, err := p.parseInterval()
+	p.pos = start
+
+	return ok && err == nil
+}
+
+// Parse "{n}", "{n,}", "{,m}" and "{n,m}". When the brace does not start a valid interval,
+// ok is false and the parser position is left untouched.
+func (p *regexParser) parseInterval() (min int, max int, ok bool, err error) {
+	start := p.pos
+	p.consumeOperator(operatorIntervalOpen)
+
+	readNumber := func() (int, bool, error) {
+		numStart := p.pos
+		for !p.atEnd() && p.peek() >= '0' && p.peek() <= '9' {
+			p.pos += 1
+		}
+		if numStart == p.pos {
+			return 0, false, nil
+		}
+		n, convErr := strconv.Atoi(string(p.pattern[numStart:p.pos]))
+		if convErr != nil || n > maxRepeatCount {
+			return 0, false, p.errorf("Regular expression too big")
+		}
+		return n, true, nil
+	}
+
+	min, hasMin, err := readNumber()
+	if err != nil {
+		return 0, 0, false, err
+	}
+
+	max = min
+	if !p.atEnd() && p.peek() == ',' {
+		p.pos += 1
+		var hasMax bool
+		max, hasMax, err = readNumber()
+		if err != nil {
+			return 0, 0, false, err
+		}
+		if !hasMax {
+			max = repeatInfinite
+		}
+		if !hasMin {
+			min = 0
+		}
+	} else if !hasMin {
+		return p.invalidInterval(start)
+	}
+
+	if !p.consumeOperator(operatorIntervalClose) {
+		return p.invalidInterval(start)
+	}
+
+	if max != repeatInfinite && max < min {
+		return 0, 0, false, p.errorf("Invalid content of \\{\\}")
+	}
+
+	return min, max, true, nil
+}
+
+// A malformed "\{" is an error in basic syntax, while a '{' is just a literal otherwise
+func (p *regexParser) invalidInterval(start int) (int, int, bool, error) {
+	if p.syntax == SyntaxBasic {
+		return 0, 0, false, p.errorf("Unmatched \\{")
+	}
+
+	p.pos = start
+	return 0, 0, false, nil
+}
+
+func (p *regexParser) parseBracket() (*charClass, error) {
+	p.pos += 1 // skip '['
+
+	class := &charClass{}
+	if !p.atEnd() && p.peek() == '^' {
+		class.negated = true
+		p.pos += 1
+	}
+
+	isFirst := true
+	for {
+		if p.atEnd() {
+			return nil, p.errorf("Unmatched [, [^, [:, [., or [=")
+		}
+
+		r := p.peek()
+		if r == ']' && !isFirst {
+			p.pos += 1
+			break
+		}
+		isFirst = false
+
+		if r == '[' {
+			if next, ok := p.peekAt(1); ok && next == ':' {
+				if err := p.parsePosixClass(class); err != nil {
+					return nil, err
+				}
+				continue
+			}
+		}
+
+		lo, isClassEscape, err := p.parseBracketRune(class)
+		if err != nil {
+			return nil, err
+		}
+		if isClassEscape {
+			continue
+		}
+
+		// a range, unless the '-' is the last character of the bracket
+		if next, ok := p.peekAt(0); ok && next == '-' {
+			if afterDash, ok := p.peekAt(1); ok && afterDash != ']' {
+				p.pos += 1
+				hi, isClassEscape, err := p.parseBracketRune(class)
+				if err != nil {
+					return nil, err
+				}
+				if isClassEscape || hi < lo {
+					return nil, p.errorf("Invalid range end")
+				}
+				class.addRange(lo, hi)
+				continue
+			}
+		}
+		class.addRange(lo, lo)
+	}
+
+	return class, nil
+}
+
+// Read one bracket member. A backslash is a literal in POSIX brackets, but an escape in Perl syntax
+// where "\d" like escapes are added to the class directly.
+func (p *regexParser) parseBracketRune(class *charClass) (rune, bool, error) {
+	r := p.peek()
+	p.pos += 1
+	if r != '\\' || p.syntax != SyntaxPerl {
+		return r, false, nil
+	}
+
+	if p.atEnd() {
+		return 0, false, p.errorf("Unmatched [, [^, [:, [., or [=")
+	}
+	r = p.peek()
+	p.pos += 1
+
+	switch r {
+	case 'd', 'D', 'w', 'W', 's', 'S':
+		class.subclasses = append(class.subclasses, escapeClass(r))
+		return 0, true, nil
+	case 'p', 'P':
+		subclass, err := p.parsePropertyEscape(r)
+		if err != nil {
+			return 0, false, err
+		}
+		class.subclasses = append(class.subclasses, subclass)
+		return 0, true, nil
+	case 'b':
+		return '\b', false, nil
+	}
+
+	lit, ok, err := p.parseLiteralEscape(r)
+	if err != nil {
+		return 0, false, err
+	}
+	if ok {
+		return lit, false, nil
+	}
+	if err := p.checkUnknownEscape(r); err != nil {
+		return 0, false, err
+	}
+
+	return r, false, nil
+}
+
+func (p *regexParser) parsePosixClass(class *charClass) error {
+	start := p.pos
+	p.pos += 2 // skip "[:"
+
+	nameStart := p.pos
+	for !p.atEnd() && p.peek() != ':' {
+		p.pos += 1
+	}
+	if next, ok := p.peekAt(1); p.atEnd() || !ok || next != ']' {
+		p.pos = start
+		return p.errorf("Unmatched [, [^, [:, [., or [=")
+	}
+	name := string(p.pattern[nameStart:p.pos])
+	p.pos += 2 // skip ":]"
+
+	tables, isFound := posixClassTables[name]
+	if !isFound {
+		return p.errorf("Invalid character class name")
+	}
+	class.tables = append(class.tables, tables...)
+	if name == "word" {
+		class.addRange('_', '_')
+	}
+
+	return nil
+}
+
+// Limit on a single "{n,m}" bound, mirrors RE_DUP_MAX of GNU regex
+const maxRepeatCount = 32767
+
+var posixClassTables = map[string][]*unicode.RangeTable{
+	"alnum":  {unicode.Letter, unicode.Digit},
+	"alpha":  {unicode.Letter},
+	"blank":  {blankTable},
+	"cntrl":  {unicode.Cc},
+	"digit":  {unicode.Digit},
+	"graph":  {unicode.L, unicode.M, unicode.N, unicode.P, unicode.S},
+	"lower":  {unicode.Lower},
+	"print":  {unicode.L, unicode.M, unicode.N, unicode.P, unicode.S, unicode.Zs},
+	"punct":  {unicode.P, unicode.S},
+	"space":  {unicode.White_Space},
+	"upper":  {unicode.Upper},
+	"xdigit": {unicode.ASCII_Hex_Digit},
+	"word":   {unicode.Letter, unicode.Digit},
+}
+
+var blankTable = &unicode.RangeTable{
+	R16: []unicode.Range16{
+		{Lo: '\t', Hi: '\t', Stride: 1},
+		{Lo: ' ', Hi: ' ', Stride: 1},
+	},
+}
+
+func escapeClass(r rune) *charClass {
+	class := &charClass{}
+	switch unicode.ToLower(r) {
+	case 'd':
+		class.tables = []*unicode.RangeTable{unicode.Digit}
+	case 'w':
+		class.tables = []*unicode.RangeTable{unicode.Letter, unicode.Digit}
+		class.addRange('_', '_')
+	case 's':
+		class.tables = []*unicode.RangeTable{unicode.White_Space}
+	}
+	class.negated = unicode.IsUpper(r)
+
+	return class
+}
+
+// Width in runes of everything the node can match, isFixed is false when it varies
+func fixedWidth(node *regexNode) (width int, isFixed bool) {
+	switch node.kind {
+	case nodeEmpty, nodeAssert, nodeLookaround:
+		return 0, true
+	case nodeLiteral, nodeAnyChar, nodeCharClass:
+		return 1, true
+	case nodeCapture, nodeGroup:
+		return fixedWidth(node.children[0])
+	case nodeConcat:
+		for _, child := range node.children {
+			childWidth, isChildFixed := fixedWidth(child)
+			if !isChildFixed {
+				return 0, false
+			}
+			width += childWidth
+		}
+		return width, true
+	case nodeAlternate:
+		for i, child := range node.children {
+			childWidth, isChildFixed := fixedWidth(child)
+			if !isChildFixed || (i > 0 && childWidth != width) {
+				return 0, false
+			}
+			width = childWidth
+		}
+		return width, true
+	case nodeRepeat:
+		if node.min != node.max {
+			return 0, false
+		}
+		childWidth, isChildFixed := fixedWidth(node.children[0])
+		return childWidth * node.min, isChildFixed
+	}
+
+	return 0, false // back references
+}
+
+// Fewest runes the node can match, a back reference may match nothing
+func minWidth(node *regexNode) int {
+	switch node.kind {
+	case nodeLiteral, nodeAnyChar, nodeCharClass:
+		return 1
+	case nodeCapture, nodeGroup:
+		return minWidth(node.children[0])
+	case nodeConcat:
+		width := 0
+		for _, child := range node.children {
+			width += minWidth(child)
+		}
+		return width
+	case nodeAlternate:
+		width := minWidth(node.children[0])
+		for _, child := range node.children[1:] {
+			width = min(width, minWidth(child))
+		}
+		return width
+	case nodeRepeat:
+		return minWidth(node.children[0]) * node.min
+	}
+
+	return 0 // empty, assertions, lookarounds and back references
+}
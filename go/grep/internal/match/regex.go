@@ -0,0 +1,199 @@
+package match
+
+import (
+	"strings"
+	"sync"
+)
+
+type regexMachine interface {
+	// Return the capture slots of the first match at or after pos, or of the match at pos when isAnchored,
+	// or nil
+	find(input []rune, pos int, isAnchored bool) []int
+}
+
+type Regexp struct {
+	expression string
+	prog       *regexProgram
+	transform  *textTransform // nil when the matches run on the input as is
+
+	machines    sync.Pool
+	dfas        sync.Pool // of *lazyDFA, without New when the program needs the backtracker
+	reverseDFAs sync.Pool // of *lazyDFA of the reversed program, like dfas
+	isLongest   bool      // the machine is the Pike VM with the leftmost-longest semantics
+}
+
+// Compile the expressions for the given syntax into one regex matching any of them. POSIX syntaxes
+// run on the Thompson NFA with leftmost-longest semantics, unless they use back references; Perl
+// syntax runs on the backtracker with leftmost-first semantics.
+func CompileRegexList(expressions []string, syntax RegexSyntax, expOptions ExpressionOption) (*Regexp, error) {
+	parsed, err := parseRegexList(expressions, syntax)
+	if err != nil {
+		return nil, err
+	}
+	expOptions, _ = resolveSmartCase(parsed, expOptions)
+
+	return compileParsedRegex(strings.Join(expressions, "\n"), parsed, syntax, expOptions)
+}
+
+func compileParsedRegex(expression string, parsed *parsedRegex, syntax RegexSyntax, expOptions ExpressionOption) (*Regexp, error) {
+	root := parsed.root
+	transform := newTextTransform(expOptions)
+	if transform != nil {
+		root = transform.literals(root)
+	}
+	if expOptions.IsWordMatch {
+		root = &regexNode{kind: nodeConcat, children: []*regexNode{
+			{kind: nodeAssert, assert: assertNotAfterWord}, root, {kind: nodeAssert, assert: assertNotBeforeWord},
+		}}
+	}
+	if expOptions.IsLineMatch {
+		root = &regexNode{kind: nodeConcat, children: []*regexNode{
+			{kind: nodeAssert, assert: assertBeginLine}, root, {kind: nodeAssert, assert: assertEndLine},
+		}}
+	}
+
+	prog, err := compileRegexProgram(root, parsed.captureCount, expOptions)
+	if err != nil {
+		if syntaxErr, ok := err.(*RegexSyntaxError); ok {
+			syntaxErr.Pattern = expression
+		}
+		return nil, err
+	}
+
+	re := &Regexp{expression: expression, prog: prog, transform: transform}
+	if !parsed.hasBackref && !parsed.hasLookaround {
+		reverseProg, err := compileRegexProgram(reverseRegexNode(root), parsed.captureCount, expOptions)
+		if err != nil {
+			return nil, err
+		}
+		classes, reverseClasses := newDFAClasses(prog), newDFAClasses(reverseProg)
+		re.dfas.New = func() interface{} { return newLazyDFA(prog, classes) }
+		re.reverseDFAs.New = func() interface{} { return newLazyDFA(reverseProg, reverseClasses) }
+	}
+	if syntax == SyntaxPerl || parsed.hasBackref || parsed.hasLookaround {
+		re.machines.New = func() interface{} { return newBacktrackMachine(prog, parsed.hasBackref, parsed.hasLookaround) }
+	} else {
+		re.machines.New = func() interface{} { return newPikeMachine(prog, true) }
+		re.isLongest = true
+	}
+
+	return re, nil
+}
+
+func (re *Regexp) String() string {
+	return re.expression
+}
+
+// Call yield with the capture slots of every non-overlapping match of s, the slots are reused between calls.
+// Unless isGroupNeeded, only the slots of the whole match are set.
+func (re *Regexp) forEachMatch(s []rune, isGroupNeeded bool, yield func(caps []int)) {
+	if re.transform != nil {
+		re.transform.forEachMatch(re, s, isGroupNeeded, yield)
+		return
+	}
+	re.forEachRawMatch(s, isGroupNeeded, yield)
+}
+
+// The DFA of the reversed program finds where the matches start, the machine only runs from them
+func (re *Regexp) forEachRawMatch(s []rune, isGroupNeeded bool, yield func(caps []int)) {
+	m := re.machines.Get().(regexMachine)
+	defer re.machines.Put(m)
+
+	var isStart []bool
+	var d *lazyDFA
+	if re.reverseDFAs.New != nil {
+		reverse := re.reverseDFAs.Get().(*lazyDFA)
+		isStart = make([]bool, len(s)+1)
+		reverse.matchStarts(s, isStart)
+		re.reverseDFAs.Put(reverse)
+
+		if re.isLongest && !isGroupNeeded {
+			d = re.dfas.Get().(*lazyDFA)
+			defer re.dfas.Put(d)
+		}
+	}
+	span := make([]int, 2)
+
+	prevStop := -1
+	for pos := 0; pos <= len(s); {
+		if isStart != nil {
+			for pos <= len(s) && !isStart[pos] {
+				pos += 1
+			}
+			if pos > len(s) {
+				break
+			}
+		}
+		var caps []int
+		if d != nil {
+			span[0], span[1] = pos, d.longestMatchEnd(s, pos)
+			if span[1] >= 0 {
+				caps = span
+			}
+		} else {
+			caps = m.find(s, pos, isStart != nil)
+		}
+		if caps == nil {
+			break
+		}
+
+		start, stop := caps[0], caps[1]
+		if start == stop && start == prevStop {
+			// an empty match right after the previous match is not a new match
+			pos = start + 1
+			continue
+		}
+		yield(caps)
+		prevStop = stop
+
+		if stop > start {
+			pos = stop
+		} else {
+			pos = stop + 1
+		}
+	}
+}
+
+// Return every non-overlapping match of s, the ranges may be empty (e.g. "x*")
+func (re *Regexp) FindAll(s []rune) []IndexRange {
+	indexRanges := make([]IndexRange, 0)
+	re.forEachMatch(s, false, func(caps []int) {
+		indexRanges = append(indexRanges, IndexRange{Start: caps[0], Stop: caps[1]})
+	})
+
+	return indexRanges
+}
+
+// Return true when s has a match, without finding where
+func (re *Regexp) isMatch(s []rune) bool {
+	if re.transform != nil {
+		s = re.transform.runes(s)
+	}
+
+	if re.dfas.New == nil {
+		m := re.machines.Get().(regexMachine)
+		defer re.machines.Put(m)
+		return m.find(s, 0, false) != nil
+	}
+	d := re.dfas.Get().(*lazyDFA)
+	defer re.dfas.Put(d)
+	return d.match(s)
+}
+
+// Satisfy CheckContainsOperation, exp is ignored since the expression is already compiled
+func (re *Regexp) Contains(s []rune, exp []rune, expOptions ExpressionOption) []IndexRange {
+	s = trimLineEndingEnd(s)
+	if !re.isMatch(s) { // the DFA rejects most lines faster than finding the matches
+		return nil
+	}
+	if expOptions.IsMatchOnly {
+		return []IndexRange{{Start: 0, Stop: len(s)}}
+	}
+
+	indexRanges := re.FindAll(s)
+	if len(indexRanges) == 0 {
+		return nil
+	}
+
+	return indexRanges
+}
@@ -0,0 +1,322 @@
+package match
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+type regexCase struct {
+	syntax     RegexSyntax
+	patterns   []string
+	expOptions ExpressionOption
+	line       string
+	expected   []IndexRange // nil when the line does not match
+}
+
+var (
+	wordMatch = ExpressionOption{IsWordMatch: true}
+	lineMatch = ExpressionOption{IsLineMatch: true}
+	nullData  = ExpressionOption{IsDotAll: true} // a line of -z outside of -P
+)
+
+var regexCases = []regexCase{
+	// basic syntax, most operators are escaped
+	{SyntaxBasic, []string{`a*b`}, ExpressionOption{}, "xaab", []IndexRange{{1, 4}}},
+	{SyntaxBasic, []string{`a\+`}, ExpressionOption{}, "caaa", []IndexRange{{1, 4}}},
+	{SyntaxBasic, []string{`a+`}, ExpressionOption{}, "aa+", []IndexRange{{1, 3}}},
+	{SyntaxBasic, []string{`\(ab\)*c`}, ExpressionOption{}, "ababc", []IndexRange{{0, 5}}},
+	{SyntaxBasic, []string{`*a`}, ExpressionOption{}, "x*a", []IndexRange{{1, 3}}},
+	{SyntaxBasic, []string{`^*`}, ExpressionOption{}, "*x", []IndexRange{{0, 1}}},
+	{SyntaxBasic, []string{`a\{2\}`}, ExpressionOption{}, "aaaaa", []IndexRange{{0, 2}, {2, 4}}},
+	{SyntaxBasic, []string{`a|b`}, ExpressionOption{}, "ab", nil},
+	{SyntaxBasic, []string{`a|b`}, ExpressionOption{}, "a|b", []IndexRange{{0, 3}}},
+	{SyntaxBasic, []string{`a\|b`}, ExpressionOption{}, "ab", []IndexRange{{0, 1}, {1, 2}}},
+	{SyntaxBasic, []string{`[[:digit:]]\+`}, ExpressionOption{}, "ab123c45", []IndexRange{{2, 5}, {6, 8}}},
+	{SyntaxBasic, []string{`x$y`}, ExpressionOption{}, "x$y", []IndexRange{{0, 3}}},
+	{SyntaxBasic, []string{`\<the\>`}, ExpressionOption{}, "other the", []IndexRange{{6, 9}}},
+	{SyntaxBasic, []string{`[]a]\+`}, ExpressionOption{}, "x]a]", []IndexRange{{1, 4}}},
+	{SyntaxBasic, []string{`[a\]`}, ExpressionOption{}, `x\`, []IndexRange{{1, 2}}},
+	{SyntaxBasic, []string{`\(a\)\1`}, ExpressionOption{}, "aba aa", []IndexRange{{4, 6}}},
+
+	// extended syntax, leftmost-longest
+	{SyntaxExtended, []string{`a|ab`}, ExpressionOption{}, "ab", []IndexRange{{0, 2}}},
+	{SyntaxExtended, []string{`(a|ab)(c|bcd)`}, ExpressionOption{}, "abcd", []IndexRange{{0, 4}}},
+	{SyntaxExtended, []string{`a{2,}`}, ExpressionOption{}, "aaaa", []IndexRange{{0, 4}}},
+	{SyntaxExtended, []string{`a{,2}`}, ExpressionOption{}, "aaa", []IndexRange{{0, 2}, {2, 3}}},
+	{SyntaxExtended, []string{`a{2`}, ExpressionOption{}, "a{2", []IndexRange{{0, 3}}},
+	{SyntaxExtended, []string{`x*`}, ExpressionOption{}, "axb", []IndexRange{{0, 0}, {1, 2}, {3, 3}}},
+	{SyntaxExtended, []string{`a)`}, ExpressionOption{}, "a)", []IndexRange{{0, 2}}},
+	{SyntaxExtended, []string{`(ab)\1`}, ExpressionOption{}, "xababx", []IndexRange{{1, 5}}},
+	{SyntaxExtended, []string{`(a)|b\1`}, ExpressionOption{}, "b", nil},
+	{SyntaxExtended, []string{`(a*)*b`}, ExpressionOption{}, "aab", []IndexRange{{0, 3}}},
+	{SyntaxExtended, []string{`()*x`}, ExpressionOption{}, "x", []IndexRange{{0, 1}}},
+
+	// Perl syntax, leftmost-first
+	{SyntaxPerl, []string{`a|ab+`}, ExpressionOption{}, "ab", []IndexRange{{0, 1}}},
+	{SyntaxPerl, []string{`a+?`}, ExpressionOption{}, "aaa", []IndexRange{{0, 1}, {1, 2}, {2, 3}}},
+	{SyntaxPerl, []string{`\d+`}, ExpressionOption{}, "a12b3", []IndexRange{{1, 3}, {4, 5}}},
+	{SyntaxPerl, []string{`[\d\s]+`}, ExpressionOption{}, "a1 2b", []IndexRange{{1, 4}}},
+	{SyntaxPerl, []string{`foo(?=bar)`}, ExpressionOption{}, "foobaz foobar", []IndexRange{{7, 10}}},
+	{SyntaxPerl, []string{`(?<!x)b`}, ExpressionOption{}, "xb ab", []IndexRange{{4, 5}}},
+	{SyntaxPerl, []string{`(?P<w>o)\1`}, ExpressionOption{}, "foo", []IndexRange{{1, 3}}},
+	{SyntaxPerl, []string{`(?:ab)+`}, ExpressionOption{}, "abab", []IndexRange{{0, 4}}},
+	{SyntaxPerl, []string{`\x41\x{42}`}, ExpressionOption{}, "AB", []IndexRange{{0, 2}}},
+	{SyntaxPerl, []string{`\p{Greek}+`}, ExpressionOption{}, "abc αβγ", []IndexRange{{4, 7}}},
+	{SyntaxPerl, []string{`[^\P{Lu}]`}, ExpressionOption{}, "aB", []IndexRange{{1, 2}}},
+	{SyntaxPerl, []string{`\bis\b`}, ExpressionOption{}, "this is", []IndexRange{{5, 7}}},
+	{SyntaxPerl, []string{`\.`}, ExpressionOption{}, "a.b", []IndexRange{{1, 2}}},
+
+	// the assertions look the other way in the reversed program that finds where the matches start
+	{SyntaxExtended, []string{`\Bo\B`}, ExpressionOption{}, "foo boo o", []IndexRange{{1, 2}, {5, 6}}},
+	{SyntaxExtended, []string{`\<o|o\>`}, ExpressionOption{}, "oxo ooo", []IndexRange{{0, 1}, {2, 3}, {4, 5}, {6, 7}}},
+	{SyntaxExtended, []string{`b*$|^a`}, ExpressionOption{}, "abb", []IndexRange{{0, 1}, {1, 3}}},
+	{SyntaxExtended, []string{`abcd`, `bc`, `cde`}, ExpressionOption{}, "xabcde bcde", []IndexRange{{1, 5}, {7, 9}}},
+	{SyntaxExtended, []string{`host[0-9]+.example.com`}, ExpressionOption{}, "host12.example.com host3xexample.com", []IndexRange{{0, 18}, {19, 36}}},
+
+	// anchors are the ends of the line, without its terminator
+	{SyntaxExtended, []string{`^a`}, ExpressionOption{}, "aa\n", []IndexRange{{0, 1}}},
+	{SyntaxExtended, []string{`a$`}, ExpressionOption{}, "aa\r\n", []IndexRange{{1, 2}}},
+	{SyntaxExtended, []string{`^$`}, ExpressionOption{}, "\n", []IndexRange{{0, 0}}},
+	{SyntaxExtended, []string{`^$`}, ExpressionOption{}, "a\n", nil},
+	{SyntaxPerl, []string{`\Aa\z`}, ExpressionOption{}, "a\n", []IndexRange{{0, 1}}},
+	{SyntaxBasic, []string{"a^"}, ExpressionOption{}, "a^", []IndexRange{{0, 2}}},
+
+	// -w and -x
+	{SyntaxExtended, []string{`fo+`}, wordMatch, "foo_x foo", []IndexRange{{6, 9}}},
+	{SyntaxExtended, []string{`fo+`}, wordMatch, "foofoo", nil},
+	{SyntaxBasic, []string{`foo`}, wordMatch, "foobar foo", []IndexRange{{7, 10}}},
+	{SyntaxPerl, []string{`a|ab`}, wordMatch, "ab", []IndexRange{{0, 2}}},
+	{SyntaxExtended, []string{`a|ab`}, lineMatch, "ab", []IndexRange{{0, 2}}},
+	{SyntaxExtended, []string{`a+`}, lineMatch, "aaa\n", []IndexRange{{0, 3}}},
+	{SyntaxBasic, []string{`a`}, lineMatch, "ab", nil},
+
+	// a NUL-terminated line of -z, '.' matches a newline and '^' and '$' stay at its ends
+	{SyntaxBasic, []string{`a.b`}, nullData, "a\nb\x00", []IndexRange{{0, 3}}},
+	{SyntaxBasic, []string{`a.b`}, ExpressionOption{}, "a\nb\x00", nil},
+	{SyntaxBasic, []string{`^b`}, nullData, "a\nb\x00", nil},
+	{SyntaxBasic, []string{`a$`}, nullData, "a\nb\x00", nil},
+	{SyntaxBasic, []string{`b$`}, nullData, "a\nb\x00", []IndexRange{{2, 3}}},
+
+	// several patterns, back references are relative to their own pattern
+	{SyntaxExtended, []string{`foo`, `ba+r`}, ExpressionOption{}, "bar foo", []IndexRange{{0, 3}, {4, 7}}},
+	{SyntaxExtended, []string{`(x)`, `(y)\1`}, ExpressionOption{}, "yx yy", []IndexRange{{1, 2}, {3, 5}}},
+	{SyntaxFixed, []string{`a.b`, `*`}, ExpressionOption{}, "axb a.b *", []IndexRange{{4, 7}, {8, 9}}},
+}
+
+func (c regexCase) String() string {
+	syntaxNames := map[RegexSyntax]string{SyntaxBasic: "-G", SyntaxExtended: "-E", SyntaxPerl: "-P", SyntaxFixed: "-F"}
+	return syntaxNames[c.syntax] + " " + strings.Join(c.patterns, " -e ") + " in " + strings.ReplaceAll(c.line, "\x00", `\0`)
+}
+
+func TestCompilePatterns(t *testing.T) {
+	for _, c := range regexCases {
+		contains, err := CompilePatterns(c.patterns, c.syntax, c.expOptions)
+		if err != nil {
+			t.Errorf("%v: %v", c, err)
+			continue
+		}
+		if found := contains([]rune(c.line), nil, c.expOptions); !slices.Equal(found, c.expected) {
+			t.Errorf("%v\n\tfound    %v\n\texpected %v", c, found, c.expected)
+		}
+	}
+}
+
+// The Pike VM gives the leftmost-longest matches of the POSIX syntaxes and the leftmost-first matches of
+// the Perl syntax like the backtracker, which runs the patterns the Pike VM cannot. The lazy DFAs only
+// tell where the machines run.
+func TestRegexEngines(t *testing.T) {
+	for _, c := range regexCases {
+		if c.syntax == SyntaxFixed {
+			continue
+		}
+		parsed, err := parseRegexList(c.patterns, c.syntax)
+		if err != nil {
+			t.Errorf("%v: %v", c, err)
+			continue
+		}
+		isPikeable := !parsed.hasBackref && !parsed.hasLookaround
+
+		engines := map[string]func(prog *regexProgram) regexMachine{}
+		switch {
+		case c.syntax == SyntaxPerl:
+			engines["backtrack"] = func(prog *regexProgram) regexMachine {
+				return newBacktrackMachine(prog, parsed.hasBackref, parsed.hasLookaround)
+			}
+			if isPikeable {
+				engines["pike-first"] = func(prog *regexProgram) regexMachine { return newPikeMachine(prog, false) }
+			}
+		case isPikeable:
+			engines["pike-longest"] = func(prog *regexProgram) regexMachine { return newPikeMachine(prog, true) }
+		default:
+			engines["backtrack"] = func(prog *regexProgram) regexMachine {
+				return newBacktrackMachine(prog, parsed.hasBackref, parsed.hasLookaround)
+			}
+		}
+
+		for name, newMachine := range engines {
+			for _, isDFA := range []bool{false, true} {
+				parsed, _ := parseRegexList(c.patterns, c.syntax) // the compilation rewrites the nodes
+				re, err := compileParsedRegex(strings.Join(c.patterns, "\n"), parsed, c.syntax, c.expOptions)
+				if err != nil {
+					t.Errorf("%v: %v", c, err)
+					continue
+				}
+				re.machines.New = func() interface{} { return newMachine(re.prog) }
+				if !isDFA {
+					re.dfas.New, re.reverseDFAs.New = nil, nil
+				}
+
+				if found := re.Contains([]rune(c.line), nil, c.expOptions); !slices.Equal(found, c.expected) {
+					t.Errorf("%s, dfa %v: %v\n\tfound    %v\n\texpected %v", name, isDFA, c, found, c.expected)
+				}
+				found := make([]IndexRange, 0) // the slots of the groups come from the machine
+				re.forEachMatch(trimLineEndingEnd([]rune(c.line)), true, func(caps []int) {
+					found = append(found, IndexRange{Start: caps[0], Stop: caps[1]})
+				})
+				if len(found) == 0 && c.expected == nil {
+					continue
+				}
+				if !slices.Equal(found, c.expected) {
+					t.Errorf("%s, dfa %v, groups: %v\n\tfound    %v\n\texpected %v", name, isDFA, c, found, c.expected)
+				}
+			}
+		}
+	}
+}
+
+func TestRegexSyntaxErrors(t *testing.T) {
+	cases := []struct {
+		syntax  RegexSyntax
+		pattern string
+		message string
+	}{
+		{SyntaxBasic, `a\{1`, `Unmatched \{`},
+		{SyntaxBasic, `\(a`, `Unmatched ( or \(`},
+		{SyntaxBasic, `a\)`, `Unmatched ) or \)`},
+		{SyntaxExtended, `(a`, `Unmatched ( or \(`},
+		{SyntaxPerl, `a)`, `Unmatched ) or \)`},
+		{SyntaxExtended, `[a`, `Unmatched [, [^, [:, [., or [=`},
+		{SyntaxExtended, `[[:foo:]]`, `Invalid character class name`},
+		{SyntaxExtended, `[z-a]`, `Invalid range end`},
+		{SyntaxExtended, `a{2,1}`, `Invalid content of \{\}`},
+		{SyntaxExtended, `a{99999}`, `Regular expression too big`},
+		{SyntaxExtended, `(a)\2`, `Invalid back reference`},
+		{SyntaxExtended, `a\`, `Trailing backslash`},
+		{SyntaxPerl, `*a`, `Quantifier does not follow a repeatable item`},
+		{SyntaxPerl, `a++`, `Possessive quantifiers are not supported`},
+		{SyntaxPerl, `(?<=a+)b`, `Lookbehind assertion is not fixed length`},
+		{SyntaxPerl, `(?<n>a)(?<n>b)`, `Duplicate group name "n"`},
+		{SyntaxPerl, `(?i)a`, `Unsupported group syntax`},
+		{SyntaxPerl, `\Qa.b\E`, `Unsupported escape sequence \Q`},
+		{SyntaxPerl, `\k<n>`, `Unsupported escape sequence \k`},
+		{SyntaxPerl, `[\g]`, `Unsupported escape sequence \g`},
+		{SyntaxPerl, `\p{Foo}`, `Unknown property name after \P or \p`},
+		{SyntaxPerl, `\p{L`, `Malformed \p sequence`},
+	}
+
+	for _, c := range cases {
+		_, err := CompilePatterns([]string{c.pattern}, c.syntax, ExpressionOption{})
+		var syntaxErr *RegexSyntaxError
+		if !errors.As(err, &syntaxErr) || syntaxErr.Message != c.message {
+			t.Errorf("%q: got error %v, expected %q", c.pattern, err, c.message)
+		}
+	}
+}
+
+// An exponential search gives up instead of running for ever
+func TestBacktrackLimit(t *testing.T) {
+	for _, syntax := range []RegexSyntax{SyntaxExtended, SyntaxPerl} {
+		contains, err := CompilePatterns([]string{`(a|a)*\1[bd]`}, syntax, ExpressionOption{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		run := func(line string) (found []IndexRange, err error) {
+			defer CatchBacktrackLimit(&err)
+			return contains([]rune(line), nil, ExpressionOption{}), nil
+		}
+		if found, err := run("aab"); err != nil || !slices.Equal(found, []IndexRange{{0, 3}}) {
+			t.Errorf("short line: found %v, error %v", found, err)
+		}
+		if _, err := run(strings.Repeat("a", 40) + "c"); err != ErrBacktrackLimit {
+			t.Errorf("long line: got error %v, expected %v", err, ErrBacktrackLimit)
+		}
+	}
+}
+
+var (
+	insensitive = ExpressionOption{IsCaseInsensitive: true}
+	smartCase   = ExpressionOption{IsSmartCase: true}
+)
+
+var caseModeCases = []regexCase{
+	{SyntaxBasic, []string{`abc`}, insensitive, "xABC", []IndexRange{{1, 4}}},
+	{SyntaxBasic, []string{`[a-c]\+`}, insensitive, "xABC", []IndexRange{{1, 4}}},
+	{SyntaxExtended, []string{`(k)\1`}, insensitive, "kK", []IndexRange{{0, 2}}},
+
+	// full case folding of the literals
+	{SyntaxBasic, []string{`strasse`}, insensitive, "Die STRAßE", []IndexRange{{4, 10}}},
+	{SyntaxBasic, []string{`ß`}, insensitive, "SS", []IndexRange{{0, 2}}},
+	{SyntaxExtended, []string{`stra(ss|x)e`}, insensitive, "straße", []IndexRange{{0, 6}}},
+	{SyntaxBasic, []string{`σ`}, insensitive, "ΟΔΟΣ οδος", []IndexRange{{3, 4}, {8, 9}}},
+	{SyntaxBasic, []string{`strasse`}, ExpressionOption{}, "straße", nil},
+
+	// -S, the escapes and the class names are not upper case letters
+	{SyntaxBasic, []string{`abc`}, smartCase, "ABC", []IndexRange{{0, 3}}},
+	{SyntaxBasic, []string{`Abc`}, smartCase, "abc", nil},
+	{SyntaxBasic, []string{`Abc`}, smartCase, "Abc", []IndexRange{{0, 3}}},
+	{SyntaxPerl, []string{`\W\d`}, smartCase, "a,1", []IndexRange{{1, 3}}},
+	{SyntaxPerl, []string{`a\W`}, smartCase, "A,", []IndexRange{{0, 2}}},
+	{SyntaxBasic, []string{`[[:upper:]]x`}, smartCase, "AX", []IndexRange{{0, 2}}},
+	{SyntaxBasic, []string{`strasse`}, smartCase, "straße", []IndexRange{{0, 6}}},
+	{SyntaxFixed, []string{`abc`, `Zzz`}, smartCase, "ABC zzz Zzz", []IndexRange{{0, 3}, {8, 11}}},
+	{SyntaxExtended, []string{`a.c`, `Z+`}, smartCase, "AXC zz ZZ", []IndexRange{{0, 3}, {7, 9}}},
+
+	// a case insensitive pattern of -S has the full case folding whatever the other patterns are
+	{SyntaxFixed, []string{`strasse`, `Zzz`}, smartCase, "STRAßE zzz Zzz", []IndexRange{{0, 6}, {11, 14}}},
+	{SyntaxExtended, []string{`stras+e`, `Z+`}, smartCase, "Straße ZZ", []IndexRange{{0, 6}, {7, 9}}},
+	{SyntaxExtended, []string{`s`, `Ss`}, smartCase, "ßSs", []IndexRange{{0, 1}, {1, 3}}},
+}
+
+func TestCaseModes(t *testing.T) {
+	for _, c := range caseModeCases {
+		contains, err := CompilePatterns(c.patterns, c.syntax, c.expOptions)
+		if err != nil {
+			t.Errorf("%v: %v", c, err)
+			continue
+		}
+		if found := contains([]rune(c.line), nil, c.expOptions); !slices.Equal(found, c.expected) {
+			t.Errorf("%v\n\tfound    %v\n\texpected %v", c, found, c.expected)
+		}
+	}
+}
+
+// With IsMatchOnly, a line matches when it has matches, the lazy DFA runs the patterns it can
+func TestMatchOnly(t *testing.T) {
+	for _, c := range append(slices.Clone(regexCases), caseModeCases...) {
+		contains, err := CompilePatterns(c.patterns, c.syntax, c.expOptions)
+		if err != nil {
+			t.Errorf("%v: %v", c, err)
+			continue
+		}
+		matchOptions := c.expOptions
+		matchOptions.IsMatchOnly = true
+		if found := contains([]rune(c.line), nil, matchOptions); (found != nil) != (c.expected != nil) {
+			t.Errorf("%v: found %v, expected a match: %v", c, found, c.expected != nil)
+		}
+
+		if c.syntax == SyntaxFixed || c.expOptions.IsSmartCase {
+			continue
+		}
+		parsed, _ := parseRegexList(c.patterns, c.syntax)
+		re, err := compileParsedRegex(strings.Join(c.patterns, "\n"), parsed, c.syntax, c.expOptions)
+		if err != nil || re.dfas.New == nil {
+			continue
+		}
+		if isMatched := re.isMatch(trimLineEndingEnd([]rune(c.line))); isMatched != (c.expected != nil) {
+			t.Errorf("dfa: %v: got %v", c, isMatched)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package match
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// A literal part of the replacement, or a reference to a capture group when group >= 0
+type replacePiece struct {
+	literal []rune
+	group   int
+}
+
+// Replace every match of the patterns with a template where "$1" or "${1}" is a capture group, "${name}"
+// a named group, "$0" the whole match and "$$" a dollar sign
+type Replacer struct {
+	re     *Regexp
+	pieces []replacePiece
+}
+
+// Literal patterns are compiled as a regex too, their only group is the whole match
+func CompileReplacer(patterns []string, syntax RegexSyntax, expOptions ExpressionOption, template string) (*Replacer, error) {
+	if len(patterns) == 0 { // e.g. "-f /dev/null", nothing is replaced
+		return &Replacer{}, nil
+	}
+
+	parsed, err := parseRegexList(patterns, syntax)
+	if err != nil {
+		return nil, err
+	}
+	expOptions, _ = resolveSmartCase(parsed, expOptions)
+
+	pieces, err := parseReplaceTemplate([]rune(template), parsed)
+	if err != nil {
+		return nil, err
+	}
+
+	re, err := compileParsedRegex(strings.Join(patterns, "\n"), parsed, syntax, expOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Replacer{re: re, pieces: pieces}, nil
+}
+
+func parseReplaceTemplate(template []rune, parsed *parsedRegex) ([]replacePiece, error) {
+	pieces := make([]replacePiece, 0)
+	literal := make([]rune, 0)
+	addGroup := func(group int) {
+		if len(literal) > 0 {
+			pieces = append(pieces, replacePiece{literal: literal, group: -1})
+			literal = make([]rune, 0)
+		}
+		pieces = append(pieces, replacePiece{group: group})
+	}
+
+	for i := 0; i < len(template); i++ {
+		if template[i] != '$' || i+1 == len(template) {
+			literal = append(literal, template[i])
+			continue
+		}
+
+		switch next := template[i+1]; {
+		case next == '$':
+			literal = append(literal, '$')
+			i += 1
+		case next >= '0' && next <= '9':
+			end := i + 1
+			for end < len(template) && template[end] >= '0' && template[end] <= '9' {
+				end += 1
+			}
+			group, err := replaceGroup(string(template[i+1:end]), parsed)
+			if err != nil {
+				return nil, err
+			}
+			addGroup(group)
+			i = end - 1
+		case next == '{':
+			end := i + 2
+			for end < len(template) && template[end] != '}' {
+				end += 1
+			}
+			if end == len(template) {
+				return nil, fmt.Errorf("unterminated group reference %q in the replacement", string(template[i:]))
+			}
+			group, err := replaceGroup(string(template[i+2:end]), parsed)
+			if err != nil {
+				return nil, err
+			}
+			addGroup(group)
+			i = end
+		default: // not a reference, e.g. "5$ each"
+			literal = append(literal, '$')
+		}
+	}
+	if len(literal) > 0 {
+		pieces = append(pieces, replacePiece{literal: literal, group: -1})
+	}
+
+	return pieces, nil
+}
+
+// Return the number of the group referenced by a number or a name
+func replaceGroup(ref string, parsed *parsedRegex) (int, error) {
+	if group, err := strconv.Atoi(ref); err == nil {
+		if group > parsed.captureCount {
+			return 0, fmt.Errorf("invalid group reference $%s in the replacement, the expressions have %d groups", ref, parsed.captureCount)
+		}
+		return group, nil
+	}
+
+	if group, isFound := parsed.captureNames[ref]; isFound {
+		return group, nil
+	}
+	return 0, fmt.Errorf("unknown group name %q in the replacement", ref)
+}
+
+// Return s with every match replaced, the ranges of the replacements in the result and the ranges of the
+// matches in s. The line ending of s is kept as is.
+func (replacer *Replacer) Replace(s []rune) ([]rune, []IndexRange, []IndexRange) {
+	if replacer.re == nil {
+		return s, nil, nil
+	}
+	line := trimLineEndingEnd(s)
+
+	replaced := make([]rune, 0, len(s))
+	replacedRanges := make([]IndexRange, 0)
+	matchedRanges := make([]IndexRange, 0)
+	last := 0
+	replacer.re.forEachMatch(line, true, func(caps []int) {
+		replaced = append(replaced, line[last:caps[0]]...)
+		start := len(replaced)
+		for _, piece := range replacer.pieces {
+			if piece.group < 0 {
+				replaced = append(replaced, piece.literal...)
+			} else if caps[2*piece.group] >= 0 { // a group that did not participate is empty
+				replaced = append(replaced, line[caps[2*piece.group]:caps[2*piece.group+1]]...)
+			}
+		}
+
+		replacedRanges = append(replacedRanges, IndexRange{Start: start, Stop: len(replaced)})
+		matchedRanges = append(matchedRanges, IndexRange{Start: caps[0], Stop: caps[1]})
+		last = caps[1]
+	})
+	replaced = append(replaced, s[last:]...)
+
+	return replaced, replacedRanges, matchedRanges
+}
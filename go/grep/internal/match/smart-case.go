@@ -0,0 +1,104 @@
+package match
+
+import "unicode"
+
+// Whether a pattern has an upper case letter of its own: the literals and the runes written in a bracket
+// count, the classes of the escapes and of the names like "\W" or "[[:upper:]]" do not
+func hasUpperCase(node *regexNode) bool {
+	switch node.kind {
+	case nodeLiteral:
+		return isUpperCase(node.r)
+	case nodeCharClass:
+		for _, rr := range node.class.ranges {
+			if isUpperCase(rr.Lo) || isUpperCase(rr.Hi) {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, child := range node.children {
+		if hasUpperCase(child) {
+			return true
+		}
+	}
+	return false
+}
+
+func isUpperCase(r rune) bool {
+	return unicode.IsUpper(r) || unicode.IsTitle(r)
+}
+
+func setCaseInsensitive(node *regexNode) {
+	node.isCaseInsensitive = true
+	for _, child := range node.children {
+		setCaseInsensitive(child)
+	}
+}
+
+// Resolve -S: a pattern without an upper case letter is case insensitive, the other ones are case
+// sensitive. When the patterns agree, the returned options apply to the whole list. Otherwise the nodes
+// of the case insensitive patterns are marked and isMixed is true: compileMixedCase matches them apart,
+// the single regex of CompileRegexList and CompileReplacer keeps the simple case folding for them.
+func resolveSmartCase(parsed *parsedRegex, expOptions ExpressionOption) (ExpressionOption, bool) {
+	if !expOptions.IsSmartCase {
+		return expOptions, false
+	}
+	expOptions.IsSmartCase = false
+
+	insensitiveRoots := make([]*regexNode, 0, len(parsed.patternRoots))
+	for _, root := range parsed.patternRoots {
+		if !hasUpperCase(root) {
+			insensitiveRoots = append(insensitiveRoots, root)
+		}
+	}
+
+	switch len(insensitiveRoots) {
+	case 0:
+		expOptions.IsCaseInsensitive = false
+		return expOptions, false
+	case len(parsed.patternRoots):
+		expOptions.IsCaseInsensitive = true
+		return expOptions, false
+	}
+
+	for _, root := range insensitiveRoots {
+		setCaseInsensitive(root)
+	}
+	expOptions.IsCaseInsensitive = false
+	return expOptions, true
+}
+
+// Match the case insensitive and the case sensitive patterns of a mixed -S list apart, so that a case
+// insensitive pattern gets the full case folding of -i whatever the other patterns are. compile builds
+// the matcher of some of the patterns.
+func compileMixedCase(patterns []string, parsed *parsedRegex, expOptions ExpressionOption,
+	compile func(patterns []string, expOptions ExpressionOption) (CheckContainsOperation, error)) (CheckContainsOperation, error) {
+	insensitivePatterns, sensitivePatterns := make([]string, 0), make([]string, 0)
+	for i, pattern := range patterns {
+		if parsed.patternRoots[i].isCaseInsensitive {
+			insensitivePatterns = append(insensitivePatterns, pattern)
+		} else {
+			sensitivePatterns = append(sensitivePatterns, pattern)
+		}
+	}
+
+	expOptions.IsSmartCase, expOptions.IsCaseInsensitive = false, true
+	insensitiveContains, err := compile(insensitivePatterns, expOptions)
+	if err != nil {
+		return nil, err
+	}
+	expOptions.IsCaseInsensitive = false
+	sensitiveContains, err := compile(sensitivePatterns, expOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(s []rune, exp []rune, expOptions ExpressionOption) []IndexRange {
+		indexRanges := append(insensitiveContains(s, exp, expOptions), sensitiveContains(s, exp, expOptions)...)
+		if len(indexRanges) == 0 {
+			return nil
+		}
+		return mergeIndexRanges(indexRanges)
+	}, nil
+}
@@ -0,0 +1,93 @@
+package match
+
+import (
+	"github.com/ikraduya/codingchallanges/go/grep/comparisonutils"
+	"github.com/ikraduya/codingchallanges/go/grep/horspool"
+	"github.com/ikraduya/codingchallanges/go/grep/kmp"
+	"github.com/ikraduya/codingchallanges/go/grep/twoway"
+)
+
+// A literal search algorithm, implemented by the kmp, horspool and twoway packages
+type SubstringSearcher interface {
+	// Return the index of the first occurrence of the pattern in s at or after start, or -1
+	Index(s []rune, start int) int
+}
+
+type SubstringAlgorithm int
+
+const (
+	AlgorithmKMP SubstringAlgorithm = iota
+	AlgorithmHorspool
+	AlgorithmTwoWay
+)
+
+// Below this length, the skips of Horspool do not pay for its table
+const minHorspoolPatternLength = 3
+
+// A pattern this long with up to maxTwoWayAlphabetSize distinct runes gets short shifts from Horspool
+// and hits its quadratic worst case, Two-Way stays linear
+const (
+	minTwoWayPatternLength = 8
+	maxTwoWayAlphabetSize  = 4
+)
+
+// Choose the algorithm from the length of the pattern and the size of its alphabet
+func SelectSubstringAlgorithm(pattern []rune, isCaseInsensitive bool) SubstringAlgorithm {
+	if len(pattern) < minHorspoolPatternLength {
+		return AlgorithmKMP
+	}
+
+	if len(pattern) < minTwoWayPatternLength {
+		return AlgorithmHorspool
+	}
+
+	_, foldFunc := runeFuncs(isCaseInsensitive)
+	alphabet := make(map[rune]struct{})
+	for _, r := range pattern {
+		alphabet[foldFunc(r)] = struct{}{}
+	}
+	if len(alphabet) <= maxTwoWayAlphabetSize {
+		return AlgorithmTwoWay
+	}
+
+	return AlgorithmHorspool
+}
+
+func runeFuncs(isCaseInsensitive bool) (comparisonutils.RuneComparisonFunc, comparisonutils.RuneFoldFunc) {
+	if isCaseInsensitive {
+		return comparisonutils.AreRunesCaseInsensitiveEqual, comparisonutils.FoldRuneCaseInsensitive
+	}
+
+	return comparisonutils.AreRunesCaseSensitiveEqual, comparisonutils.FoldRuneCaseSensitive
+}
+
+func NewSubstringSearcher(pattern []rune, isCaseInsensitive bool, algorithm SubstringAlgorithm) SubstringSearcher {
+	comparisonFunc, foldFunc := runeFuncs(isCaseInsensitive)
+
+	switch algorithm {
+	case AlgorithmHorspool:
+		return horspool.NewSearcher(pattern, comparisonFunc, foldFunc)
+	case AlgorithmTwoWay:
+		return twoway.NewSearcher(pattern, comparisonFunc, foldFunc)
+	default:
+		return kmp.NewSearcher(pattern, comparisonFunc)
+	}
+}
+
+// Return the non-overlapping occurrences of a pattern of patternLen runes, or nil
+func FindAllSubstrings(s []rune, searcher SubstringSearcher, patternLen int) []IndexRange {
+	indexRanges := make([]IndexRange, 0)
+	for start := 0; start+patternLen <= len(s); {
+		i := searcher.Index(s, start)
+		if i < 0 {
+			break
+		}
+		indexRanges = append(indexRanges, IndexRange{Start: i, Stop: i + patternLen})
+		start = i + patternLen
+	}
+
+	if len(indexRanges) == 0 {
+		return nil
+	}
+	return indexRanges
+}
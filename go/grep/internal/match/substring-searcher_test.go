@@ -0,0 +1,158 @@
+package match
+
+import (
+	"math/rand"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/ikraduya/codingchallanges/go/grep/comparisonutils"
+)
+
+var substringAlgorithms = []struct {
+	name      string
+	algorithm SubstringAlgorithm
+}{
+	{"kmp", AlgorithmKMP},
+	{"horspool", AlgorithmHorspool},
+	{"twoway", AlgorithmTwoWay},
+}
+
+// Small alphabets make repetitive patterns, the last ones have runes whose case folding is not ASCII
+var randomAlphabets = [][]rune{
+	[]rune("ab"),
+	[]rune("abcd"),
+	[]rune("abcdefghijklmnopqrstuvwxyz "),
+	[]rune("aAbB"),
+	[]rune("kKKsSſ"),
+	[]rune("é€😀éE"),
+}
+
+func naiveFindAll(s []rune, pattern []rune, isCaseInsensitive bool) []IndexRange {
+	equals := comparisonutils.AreRunesCaseSensitiveEqual
+	if isCaseInsensitive {
+		equals = comparisonutils.AreRunesCaseInsensitiveEqual
+	}
+
+	indexRanges := make([]IndexRange, 0)
+	for i := 0; i+len(pattern) <= len(s); {
+		k := 0
+		for k < len(pattern) && equals(s[i+k], pattern[k]) {
+			k += 1
+		}
+		if k == len(pattern) {
+			indexRanges = append(indexRanges, IndexRange{Start: i, Stop: i + len(pattern)})
+			i += len(pattern)
+			continue
+		}
+		i += 1
+	}
+
+	if len(indexRanges) == 0 {
+		return nil
+	}
+	return indexRanges
+}
+
+func randomRunes(rng *rand.Rand, alphabet []rune, n int) []rune {
+	runes := make([]rune, n)
+	for i := range runes {
+		runes[i] = alphabet[rng.Intn(len(alphabet))]
+	}
+
+	return runes
+}
+
+// Half of the patterns are taken from the text so that most of them are found
+func randomSubstringCase(rng *rand.Rand) ([]rune, []rune, bool) {
+	alphabet := randomAlphabets[rng.Intn(len(randomAlphabets))]
+	s := randomRunes(rng, alphabet, rng.Intn(200))
+
+	patternLen := 1 + rng.Intn(12)
+	pattern := randomRunes(rng, alphabet, patternLen)
+	if rng.Intn(2) == 0 && patternLen <= len(s) {
+		start := rng.Intn(len(s) - patternLen + 1)
+		pattern = slices.Clone(s[start : start+patternLen])
+	}
+
+	return s, pattern, rng.Intn(2) == 0
+}
+
+// Every searcher finds the same occurrences as the naive search
+func FuzzSearchers(f *testing.F) {
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 2000; i++ {
+		s, pattern, isCaseInsensitive := randomSubstringCase(rng)
+		f.Add(string(s), string(pattern), isCaseInsensitive)
+	}
+
+	f.Fuzz(func(t *testing.T, text string, patternText string, isCaseInsensitive bool) {
+		if !utf8.ValidString(text) || !utf8.ValidString(patternText) || patternText == "" {
+			t.Skip()
+		}
+		s, pattern := []rune(text), []rune(patternText)
+
+		expected := naiveFindAll(s, pattern, isCaseInsensitive)
+		for _, a := range substringAlgorithms {
+			searcher := NewSubstringSearcher(pattern, isCaseInsensitive, a.algorithm)
+			found := FindAllSubstrings(s, searcher, len(pattern))
+			if !slices.Equal(found, expected) {
+				t.Fatalf("%s: pattern %q in %q (case insensitive: %v)\n\tfound    %v\n\texpected %v",
+					a.name, patternText, text, isCaseInsensitive, found, expected)
+			}
+		}
+	})
+}
+
+var benchmarkTexts = sync.OnceValues(func() ([]rune, []rune) {
+	rng := rand.New(rand.NewSource(1))
+
+	words := strings.Fields("the quick brown fox jumps over lazy dog grep pattern search line match")
+	var sb strings.Builder
+	for sb.Len() < 8*1024*1024 {
+		sb.WriteString(words[rng.Intn(len(words))])
+		sb.WriteString(" ")
+	}
+	text := []rune(sb.String())
+
+	return text, randomRunes(rng, []rune("ab"), len(text))
+})
+
+func benchmarkSubstringAlgorithm(b *testing.B, algorithm SubstringAlgorithm) {
+	text, binary := benchmarkTexts()
+	cases := []struct {
+		name    string
+		s       []rune
+		pattern string
+	}{
+		{"short", text, "fox"},
+		{"medium", text, "jumps over"},
+		{"long", text, "the lazy dog was not there at all"},
+		{"binary", binary, "abababababababbbababbbab"},
+	}
+
+	for _, c := range cases {
+		b.Run(c.name, func(b *testing.B) {
+			pattern := []rune(c.pattern)
+			searcher := NewSubstringSearcher(pattern, false, algorithm)
+			for b.Loop() {
+				FindAllSubstrings(c.s, searcher, len(pattern))
+			}
+			b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N)/float64(len(c.s)), "ns/rune")
+		})
+	}
+}
+
+func BenchmarkKMP(b *testing.B) {
+	benchmarkSubstringAlgorithm(b, AlgorithmKMP)
+}
+
+func BenchmarkHorspool(b *testing.B) {
+	benchmarkSubstringAlgorithm(b, AlgorithmHorspool)
+}
+
+func BenchmarkTwoWay(b *testing.B) {
+	benchmarkSubstringAlgorithm(b, AlgorithmTwoWay)
+}
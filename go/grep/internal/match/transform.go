@@ -0,0 +1,121 @@
+package match
+
+import "github.com/ikraduya/codingchallanges/go/grep/internal/normalize"
+
+// The view of the lines and of the pattern literals given by the full case folding of -i and the form of
+// --normalize. The matchers run on the transformed runes and the matches are mapped back to the original
+// ones. The matchers stay case insensitive with -i, so most lines need no transformation, and the classes
+// keep the simple case folding, e.g. "[ß]" does not match "SS".
+type textTransform struct {
+	form     normalize.Form
+	isFolded bool
+}
+
+// Return nil when the options leave the text as is
+func newTextTransform(expOptions ExpressionOption) *textTransform {
+	if !expOptions.IsCaseInsensitive && expOptions.Normalization == normalize.FormNone {
+		return nil
+	}
+	return &textTransform{form: expOptions.Normalization, isFolded: expOptions.IsCaseInsensitive}
+}
+
+// Return the transformed runes and their offsets, or s and nil offsets when the transformation would not
+// change s beyond what the case insensitive matchers already fold, e.g. when s is ASCII
+func (transform *textTransform) apply(s []rune) ([]rune, normalize.Offsets) {
+	if normalize.QuickCheck(s, transform.form, transform.isFolded) {
+		return s, nil
+	}
+
+	return normalize.Transform(s, transform.form, transform.isFolded)
+}
+
+func (transform *textTransform) runes(s []rune) []rune {
+	transformed, _ := transform.apply(s)
+	return transformed
+}
+
+// Transform the literals of a regex, the consecutive literals of a concatenation together so that e.g. "e"
+// followed by a combining acute accent compose with NFC
+func (transform *textTransform) literals(node *regexNode) *regexNode {
+	switch node.kind {
+	case nodeLiteral:
+		return literalNode(transform.runes([]rune{node.r}))
+	case nodeConcat:
+		children := make([]*regexNode, 0, len(node.children))
+		run := make([]rune, 0)
+		flushRun := func() {
+			for _, r := range transform.runes(run) {
+				children = append(children, &regexNode{kind: nodeLiteral, r: r})
+			}
+			run = run[:0]
+		}
+		for _, child := range node.children {
+			if child.kind == nodeLiteral {
+				run = append(run, child.r)
+				continue
+			}
+			flushRun()
+			children = append(children, transform.literals(child))
+		}
+		flushRun()
+		node.children = children
+	default:
+		for i, child := range node.children {
+			node.children[i] = transform.literals(child)
+		}
+	}
+
+	return node
+}
+
+// Wrap a CheckContainsOperation of transformed patterns so that it runs on the transformed line and returns
+// ranges of the original line. The ranges that fall into one original rune, e.g. both 's' of the folding
+// of 'ß', are merged.
+func (transform *textTransform) wrap(checkContainsFunc CheckContainsOperation) CheckContainsOperation {
+	return func(s []rune, exp []rune, expOptions ExpressionOption) []IndexRange {
+		transformed, offsets := transform.apply(s)
+		indexRanges := checkContainsFunc(transformed, exp, expOptions)
+		if offsets == nil || indexRanges == nil {
+			return indexRanges
+		}
+
+		originalRanges := make([]IndexRange, 0, len(indexRanges))
+		for _, indexRange := range indexRanges {
+			start, stop := offsets.Original(indexRange.Start, indexRange.Stop)
+			if n := len(originalRanges); n > 0 && start < originalRanges[n-1].Stop {
+				originalRanges[n-1].Stop = max(originalRanges[n-1].Stop, stop)
+				continue
+			}
+			originalRanges = append(originalRanges, IndexRange{Start: start, Stop: stop})
+		}
+		return originalRanges
+	}
+}
+
+// Call yield with the capture slots of the matches of the transformed s mapped back to s, a match that
+// starts within the original runes of the previous match is skipped
+func (transform *textTransform) forEachMatch(re *Regexp, s []rune, isGroupNeeded bool, yield func(caps []int)) {
+	transformed, offsets := transform.apply(s)
+	if offsets == nil {
+		re.forEachRawMatch(s, isGroupNeeded, yield)
+		return
+	}
+
+	var originalCaps []int
+	prevStop := -1
+	re.forEachRawMatch(transformed, isGroupNeeded, func(caps []int) {
+		originalCaps = append(originalCaps[:0], caps...)
+		for i := 0; i < len(originalCaps); i += 2 {
+			if originalCaps[i] >= 0 {
+				originalCaps[i], originalCaps[i+1] = offsets.Original(originalCaps[i], originalCaps[i+1])
+			}
+		}
+
+		start, stop := originalCaps[0], originalCaps[1]
+		if start < prevStop || (start == stop && start == prevStop) {
+			return
+		}
+		prevStop = stop
+		yield(originalCaps)
+	})
+}
@@ -0,0 +1,317 @@
+// Package normalize implements the Unicode normalization forms and the full case folding of text, keeping
+// track of where each transformed rune comes from so that matches can be reported on the original text.
+package normalize
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"unicode/utf8"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+
+	"github.com/ikraduya/codingchallanges/go/grep/comparisonutils"
+)
+
+type Form int
+
+const (
+	FormNone Form = iota
+	FormNFC
+	FormNFD
+	FormNFKC
+	FormNFKD
+)
+
+var formNames = map[string]Form{
+	"nfc":  FormNFC,
+	"nfd":  FormNFD,
+	"nfkc": FormNFKC,
+	"nfkd": FormNFKD,
+}
+
+func ParseForm(name string) (Form, error) {
+	form, ok := formNames[name]
+	if !ok {
+		return FormNone, fmt.Errorf("invalid normalization form %q, expected nfc, nfd, nfkc or nfkd", name)
+	}
+	return form, nil
+}
+
+func (form Form) isComposed() bool {
+	return form == FormNFC || form == FormNFKC
+}
+
+func (form Form) isCompat() bool {
+	return form == FormNFKC || form == FormNFKD
+}
+
+func (form Form) normForm() norm.Form {
+	switch form {
+	case FormNFC:
+		return norm.NFC
+	case FormNFD:
+		return norm.NFD
+	case FormNFKC:
+		return norm.NFKC
+	}
+	return norm.NFKD
+}
+
+// Canonical combining class of r, 0 for a starter
+func combiningClass(r rune) uint8 {
+	if r < 0x300 {
+		return 0
+	}
+	var buf [utf8.UTFMax]byte
+	return norm.NFD.Properties(utf8.AppendRune(buf[:0], r)).CCC()
+}
+
+// Hangul syllables are decomposed into jamos and composed back by arithmetic
+const (
+	hangulBase   = 0xAC00
+	hangulLBase  = 0x1100
+	hangulVBase  = 0x1161
+	hangulTBase  = 0x11A7
+	hangulLCount = 19
+	hangulVCount = 21
+	hangulTCount = 28
+	hangulNCount = hangulVCount * hangulTCount
+	hangulCount  = hangulLCount * hangulNCount
+)
+
+func decompose(r rune, form Form) []rune {
+	if r >= hangulBase && r < hangulBase+hangulCount {
+		index := r - hangulBase
+		l := hangulLBase + index/hangulNCount
+		v := hangulVBase + (index%hangulNCount)/hangulTCount
+		if t := index % hangulTCount; t != 0 {
+			return []rune{l, v, hangulTBase + t}
+		}
+		return []rune{l, v}
+	}
+	var buf [utf8.UTFMax]byte
+	normForm := norm.NFD
+	if form.isCompat() {
+		normForm = norm.NFKD
+	}
+	decomposed := normForm.Properties(utf8.AppendRune(buf[:0], r)).Decomposition()
+	if decomposed == nil {
+		return nil
+	}
+	return []rune(string(decomposed))
+}
+
+func compose(first rune, second rune) (rune, bool) {
+	if first >= hangulLBase && first < hangulLBase+hangulLCount && second >= hangulVBase && second < hangulVBase+hangulVCount {
+		return hangulBase + ((first-hangulLBase)*hangulVCount+second-hangulVBase)*hangulTCount, true
+	}
+	if first >= hangulBase && first < hangulBase+hangulCount && (first-hangulBase)%hangulTCount == 0 &&
+		second > hangulTBase && second < hangulTBase+hangulTCount {
+		return first + second - hangulTBase, true
+	}
+
+	// second only composes with first when it cannot start a segment, the composition of the pair is then
+	// the one of NFC, that leaves the excluded composites decomposed
+	var buf [utf8.UTFMax]byte
+	if norm.NFC.Properties(utf8.AppendRune(buf[:0], second)).BoundaryBefore() {
+		return 0, false
+	}
+	composed := []rune(norm.NFC.String(string([]rune{first, second})))
+	if len(composed) != 1 {
+		return 0, false
+	}
+	return composed[0], true
+}
+
+// Full case folding of r when it folds into several runes, e.g. "ß" into "ss", nil otherwise
+func fullFold(r rune) []rune {
+	if folded, ok := fullFolds.Load(r); ok {
+		return folded.([]rune)
+	}
+
+	var folded []rune
+	if runes := []rune(cases.Fold().String(string(r))); len(runes) > 1 {
+		folded = runes
+	}
+	fullFolds.Store(r, folded)
+	return folded
+}
+
+// Cache of fullFold by rune, a Caser of the cases package cannot be shared
+var fullFolds sync.Map
+
+// Return true when Transform leaves s as is, up to the simple case folding when isFolded: no rune has a full
+// case folding and s is already in the form
+func QuickCheck(s []rune, form Form, isFolded bool) bool {
+	isASCII := true
+	for _, r := range s {
+		if r < 0x80 {
+			continue
+		}
+		isASCII = false
+		if isFolded && fullFold(r) != nil {
+			return false
+		}
+	}
+	if isASCII || form == FormNone { // ASCII is in every form
+		return true
+	}
+
+	return form.normForm().IsNormalString(string(s))
+}
+
+// Where the transformed runes come from: offsets[i] is the index in the original text of the first rune
+// the i-th transformed rune was made of, offsets[len(transformed)] is the length of the original text.
+// The offsets never decrease, runes that were reordered or composed together share one offset.
+type Offsets []int
+
+// Map a range of the transformed text back to the original text, a range that covers a part of the runes
+// made of one original rune, e.g. the first 's' of the folding of 'ß', covers the whole original rune
+func (offsets Offsets) Original(start int, stop int) (int, int) {
+	if start == stop {
+		return offsets[start], offsets[start]
+	}
+	for stop < len(offsets)-1 && offsets[stop] == offsets[stop-1] {
+		stop++
+	}
+	return offsets[start], offsets[stop]
+}
+
+// Transform s into the normalization form, after folding the case when isFolded like the canonical caseless
+// matching of Unicode: NFD(fold(NFD(s))) before the composition. FormNone with isFolded only folds the case.
+func Transform(s []rune, form Form, isFolded bool) ([]rune, Offsets) {
+	runes := s
+	offsets := make(Offsets, len(s)+1)
+	for i := range offsets {
+		offsets[i] = i
+	}
+
+	if form != FormNone {
+		runes, offsets = decomposeRunes(runes, offsets, form, false)
+		reorderMarks(runes, offsets)
+	}
+	if isFolded {
+		runes, offsets = decomposeRunes(runes, offsets, form, true)
+		if form != FormNone {
+			reorderMarks(runes, offsets)
+		}
+	}
+	if form.isComposed() {
+		runes, offsets = composeRunes(runes, offsets)
+	}
+
+	return runes, offsets
+}
+
+// Decompose every rune into the form, and fold it when isFolded. A folding may need a decomposition and a
+// compatibility decomposition a folding, e.g. "㎁" -> "nA".
+func decomposeRunes(s []rune, offsets Offsets, form Form, isFolded bool) ([]rune, Offsets) {
+	runes := make([]rune, 0, len(s))
+	decomposedOffsets := make(Offsets, 0, len(s)+1)
+
+	var appendRune func(r rune, offset int, depth int)
+	appendRune = func(r rune, offset int, depth int) {
+		if form != FormNone {
+			if decomposed := decompose(r, form); decomposed != nil {
+				for _, d := range decomposed {
+					appendRune(d, offset, depth+1)
+				}
+				return
+			}
+		}
+		if isFolded && depth < 4 {
+			if folded := fullFold(r); folded != nil {
+				for _, f := range folded {
+					appendRune(f, offset, depth+1)
+				}
+				return
+			}
+			if folded := comparisonutils.FoldRuneCaseInsensitive(r); folded != r {
+				appendRune(folded, offset, depth+1)
+				return
+			}
+		}
+		runes = append(runes, r)
+		decomposedOffsets = append(decomposedOffsets, offset)
+	}
+	for i, r := range s {
+		if r < 0x80 { // ASCII is never decomposed
+			if isFolded {
+				r = comparisonutils.FoldRuneCaseInsensitive(r)
+			}
+			runes = append(runes, r)
+			decomposedOffsets = append(decomposedOffsets, offsets[i])
+			continue
+		}
+		appendRune(r, offsets[i], 0)
+	}
+
+	return runes, append(decomposedOffsets, offsets[len(s)])
+}
+
+// Sort each sequence of combining marks by combining class, the marks of a sequence that changed share the
+// offset of its first mark
+func reorderMarks(runes []rune, offsets Offsets) {
+	for start := 0; start < len(runes); {
+		if combiningClass(runes[start]) == 0 {
+			start++
+			continue
+		}
+		stop := start + 1
+		for stop < len(runes) && combiningClass(runes[stop]) != 0 {
+			stop++
+		}
+
+		if stop-start > 1 {
+			marks := runes[start:stop]
+			isSorted := slices.IsSortedFunc(marks, func(a rune, b rune) int {
+				return int(combiningClass(a)) - int(combiningClass(b))
+			})
+			if !isSorted {
+				slices.SortStableFunc(marks, func(a rune, b rune) int {
+					return int(combiningClass(a)) - int(combiningClass(b))
+				})
+				for i := start + 1; i < stop; i++ {
+					offsets[i] = offsets[start]
+				}
+			}
+		}
+		start = stop
+	}
+}
+
+// Canonical composition of decomposed and reordered runes, a composite takes the offset of its starter and
+// the runes between the starter and the last composed rune share it
+func composeRunes(runes []rune, offsets Offsets) ([]rune, Offsets) {
+	composed := runes[:0]
+	composedOffsets := offsets[:0]
+
+	starter := -1 // index in composed
+	var lastClass uint8
+	for i, r := range runes {
+		class := combiningClass(r)
+		if starter >= 0 {
+			isBlocked := len(composed)-1 != starter && (lastClass == 0 || lastClass >= class)
+			if !isBlocked {
+				if composite, ok := compose(composed[starter], r); ok {
+					composed[starter] = composite
+					for j := starter + 1; j < len(composedOffsets); j++ {
+						composedOffsets[j] = composedOffsets[starter]
+					}
+					continue
+				}
+			}
+		}
+
+		if class == 0 {
+			starter = len(composed)
+		}
+		lastClass = class
+		composed = append(composed, r)
+		composedOffsets = append(composedOffsets, offsets[i])
+	}
+
+	return composed, append(composedOffsets, offsets[len(runes)])
+}
@@ -0,0 +1,107 @@
+package normalize
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestTransform(t *testing.T) {
+	cases := []struct {
+		s        string
+		form     Form
+		isFolded bool
+		expected string
+		offsets  Offsets
+	}{
+		// full case folding
+		{"Stra\u00dfe", FormNone, true, "strasse", Offsets{0, 1, 2, 3, 4, 4, 5, 6}},
+		{"STRASSE", FormNone, true, "strasse", Offsets{0, 1, 2, 3, 4, 5, 6, 7}},
+		{"\u039f\u0394\u039f\u03a3 \u03bf\u03b4\u03bf\u03c2", FormNone, true, "\u03bf\u03b4\u03bf\u03c3 \u03bf\u03b4\u03bf\u03c3", Offsets{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}},
+		{"\u0130", FormNone, true, "i\u0307", Offsets{0, 0, 1}},
+		{"\ufb03", FormNone, true, "ffi", Offsets{0, 0, 0, 1}},
+		{"Stra\u00dfe", FormNone, false, "Stra\u00dfe", Offsets{0, 1, 2, 3, 4, 5, 6}},
+
+		// normalization forms
+		{"e\u0301", FormNFC, false, "\u00e9", Offsets{0, 2}},
+		{"\u00e9", FormNFD, false, "e\u0301", Offsets{0, 0, 1}},
+		{"x \ufb01le", FormNFKC, false, "x file", Offsets{0, 1, 2, 2, 3, 4, 5}},
+		{"\ufb01", FormNFD, false, "\ufb01", Offsets{0, 1}},
+		{"\u00b2", FormNFKD, false, "2", Offsets{0, 1}},
+		{"a\u0301\u0323", FormNFD, false, "a\u0323\u0301", Offsets{0, 1, 1, 3}},
+		{"a\u0301\u0323", FormNFC, false, "\u1ea1\u0301", Offsets{0, 1, 3}},
+		{"\ud55c", FormNFD, false, "\u1112\u1161\u11ab", Offsets{0, 0, 0, 1}},
+		{"\u1112\u1161\u11ab", FormNFC, false, "\ud55c", Offsets{0, 3}},
+
+		// both, e.g. the canonical caseless matching of "Å" (Angstrom sign)
+		{"\u212b", FormNFC, true, "\u00e5", Offsets{0, 1}},
+		{"\u00c9", FormNFD, true, "e\u0301", Offsets{0, 0, 1}},
+		{"\u3381", FormNFKC, true, "na", Offsets{0, 0, 1}},
+	}
+
+	for _, c := range cases {
+		transformed, offsets := Transform([]rune(c.s), c.form, c.isFolded)
+		if string(transformed) != c.expected || !slices.Equal(offsets, c.offsets) {
+			t.Errorf("%q (form %d, folded %v): got %q %v, expected %q %v",
+				c.s, c.form, c.isFolded, string(transformed), offsets, c.expected, c.offsets)
+		}
+	}
+}
+
+// A match of the transformed text covers the whole original runes it was made of
+func TestOffsetsOriginal(t *testing.T) {
+	cases := []struct {
+		s           string
+		form        Form
+		isFolded    bool
+		start, stop int // in the transformed text
+		expected    [2]int
+	}{
+		{"Stra\u00dfe", FormNone, true, 4, 5, [2]int{4, 5}}, // the first 's' of "ss"
+		{"Stra\u00dfe", FormNone, true, 5, 7, [2]int{4, 6}}, // the second 's' and the 'e'
+		{"Stra\u00dfe", FormNone, true, 0, 7, [2]int{0, 6}},
+		{"Stra\u00dfe", FormNone, true, 6, 6, [2]int{5, 5}},
+		{"x \ufb01le", FormNFKC, false, 2, 4, [2]int{2, 3}},
+		{"x \ufb01le", FormNFKC, false, 3, 5, [2]int{2, 4}},
+		{"ne\u0301e", FormNFC, false, 1, 2, [2]int{1, 3}},
+		{"n\u00e9", FormNFD, false, 0, 2, [2]int{0, 2}},
+	}
+
+	for _, c := range cases {
+		_, offsets := Transform([]rune(c.s), c.form, c.isFolded)
+		start, stop := offsets.Original(c.start, c.stop)
+		if [2]int{start, stop} != c.expected {
+			t.Errorf("%q [%d, %d): got [%d, %d), expected %v", c.s, c.start, c.stop, start, stop, c.expected)
+		}
+	}
+}
+
+// QuickCheck is true when Transform leaves the text as is, up to the simple case folding
+func TestQuickCheck(t *testing.T) {
+	cases := []struct {
+		s        string
+		form     Form
+		isFolded bool
+		expected bool
+	}{
+		{"plain ASCII", FormNone, true, true},
+		{"plain ASCII", FormNFKD, true, true},
+		{"Stra\u00dfe", FormNone, true, false},
+		{"Stra\u00dfe", FormNone, false, true},
+		{"\u03a9mega", FormNone, true, true},
+		{"\u00e9", FormNFC, false, true},
+		{"\u00e9", FormNFD, false, false},
+		{"e\u0301", FormNFC, false, false},
+		{"e\u0301", FormNFD, false, true},
+		{"\ufb01", FormNFC, false, true},
+		{"\ufb01", FormNFKC, false, false},
+		{"a\u0301\u0323", FormNFD, false, false},
+		{"\ud55c", FormNFC, false, true},
+		{"\ud55c", FormNFD, false, false},
+	}
+
+	for _, c := range cases {
+		if isUnchanged := QuickCheck([]rune(c.s), c.form, c.isFolded); isUnchanged != c.expected {
+			t.Errorf("%q (form %d, folded %v): got %v, expected %v", c.s, c.form, c.isFolded, isUnchanged, c.expected)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package output
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/mattn/go-isatty"
+)
+
+type ColorMode int
+
+const (
+	ColorAuto ColorMode = iota
+	ColorAlways
+	ColorNever
+)
+
+func ParseColorMode(value string) (ColorMode, error) {
+	switch value {
+	case "auto", "tty", "if-tty":
+		return ColorAuto, nil
+	case "always", "yes", "force":
+		return ColorAlways, nil
+	case "never", "no", "none":
+		return ColorNever, nil
+	}
+
+	return ColorAuto, fmt.Errorf("invalid argument %q for --color", value)
+}
+
+// Whether the output is colored. In auto mode it is when stdout is a terminal, unless NO_COLOR is set
+// or the terminal is dumb.
+func IsColorEnabled(mode ColorMode) bool {
+	switch mode {
+	case ColorAlways:
+		return true
+	case ColorNever:
+		return false
+	}
+
+	if os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	fd := os.Stdout.Fd()
+	return isatty.IsTerminal(fd) || isatty.IsCygwinTerminal(fd)
+}
+
+type ColorFunc func(a ...interface{}) string
+
+type TextColorTheme struct {
+	SelectedMatch ColorFunc // ms
+	ContextMatch  ColorFunc // mc
+	SelectedLine  ColorFunc // sl
+	ContextLine   ColorFunc // cx
+	Filepath      ColorFunc // fn
+	LineNumber    ColorFunc // ln, also used for the column
+	ByteOffset    ColorFunc // bn
+	Separator     ColorFunc // se
+}
+
+func NormalTextColor() ColorFunc {
+	return func(a ...interface{}) string { return fmt.Sprint(a...) }
+}
+
+func NoColorTextColorTheme() TextColorTheme {
+	return TextColorTheme{
+		SelectedMatch: NormalTextColor(),
+		ContextMatch:  NormalTextColor(),
+		SelectedLine:  NormalTextColor(),
+		ContextLine:   NormalTextColor(),
+		Filepath:      NormalTextColor(),
+		LineNumber:    NormalTextColor(),
+		ByteOffset:    NormalTextColor(),
+		Separator:     NormalTextColor(),
+	}
+}
+
+// The color is forced on, whether the output is colored is decided before the theme is chosen
+func sgrTextColor(attributes ...color.Attribute) ColorFunc {
+	if len(attributes) == 0 {
+		return NormalTextColor()
+	}
+
+	c := color.New(attributes...)
+	c.EnableColor()
+	return c.SprintFunc()
+}
+
+func DefaultTextColorTheme() TextColorTheme {
+	return TextColorTheme{
+		SelectedMatch: sgrTextColor(color.FgHiRed, color.Bold),
+		ContextMatch:  sgrTextColor(color.FgHiRed, color.Bold),
+		SelectedLine:  NormalTextColor(),
+		ContextLine:   NormalTextColor(),
+		Filepath:      sgrTextColor(color.FgHiMagenta),
+		LineNumber:    sgrTextColor(color.FgGreen),
+		ByteOffset:    sgrTextColor(color.FgGreen),
+		Separator:     sgrTextColor(color.FgCyan),
+	}
+}
+
+// Override the default theme with a GREP_COLORS value like "ms=01;31:fn=35:se=36".
+// Unknown capabilities and malformed entries are ignored like GNU grep does.
+func GrepColorsTextColorTheme(grepColors string) TextColorTheme {
+	theme := DefaultTextColorTheme()
+
+	for _, entry := range strings.Split(grepColors, ":") {
+		name, value, hasValue := strings.Cut(entry, "=")
+		if !hasValue {
+			continue // boolean capabilities like "ne" and "rv" are not supported
+		}
+
+		attributes, isValid := parseSGR(value)
+		if !isValid {
+			continue
+		}
+		textColor := sgrTextColor(attributes...)
+
+		switch name {
+		case "mt":
+			theme.SelectedMatch = textColor
+			theme.ContextMatch = textColor
+		case "ms":
+			theme.SelectedMatch = textColor
+		case "mc":
+			theme.ContextMatch = textColor
+		case "sl":
+			theme.SelectedLine = textColor
+		case "cx":
+			theme.ContextLine = textColor
+		case "fn":
+			theme.Filepath = textColor
+		case "ln":
+			theme.LineNumber = textColor
+		case "bn":
+			theme.ByteOffset = textColor
+		case "se":
+			theme.Separator = textColor
+		}
+	}
+
+	return theme
+}
+
+// Parse a "Select Graphic Rendition" sequence like "01;31", an empty one is no color
+func parseSGR(value string) ([]color.Attribute, bool) {
+	if value == "" {
+		return nil, true
+	}
+
+	attributes := make([]color.Attribute, 0)
+	for _, field := range strings.Split(value, ";") {
+		n, err := strconv.Atoi(field)
+		if err != nil || n < 0 {
+			return nil, false
+		}
+		attributes = append(attributes, color.Attribute(n))
+	}
+
+	return attributes, true
+}
+
+// Choose the theme of the output from the --color mode and the GREP_COLORS environment variable
+func NewTextColorTheme(mode ColorMode) TextColorTheme {
+	if !IsColorEnabled(mode) {
+		return NoColorTextColorTheme()
+	}
+
+	return GrepColorsTextColorTheme(os.Getenv("GREP_COLORS"))
+}
@@ -0,0 +1,64 @@
+package output
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// Lines of context around the changed lines of a hunk, like diff -u
+const diffContextLines = 3
+
+func writeDiffLine(w io.Writer, mark string, line []byte) {
+	io.WriteString(w, mark)
+	w.Write(line)
+	if !bytes.HasSuffix(line, []byte("\n")) {
+		io.WriteString(w, "\n\\ No newline at end of file\n")
+	}
+}
+
+// Print a unified diff of a file whose lines were replaced one for one, changedLines are the
+// increasing 0-based indexes of the lines that differ
+func OutputUnifiedDiff(w io.Writer, filepath string, oldLines [][]byte, newLines [][]byte, changedLines []int) {
+	if len(changedLines) == 0 {
+		return
+	}
+
+	fmt.Fprintf(w, "--- %s\n+++ %s\n", filepath, filepath)
+	for first := 0; first < len(changedLines); {
+		// the changes separated by at most twice the context share a hunk
+		last := first
+		for last+1 < len(changedLines) && changedLines[last+1]-changedLines[last] <= 2*diffContextLines+1 {
+			last += 1
+		}
+
+		start := max(0, changedLines[first]-diffContextLines)
+		end := min(len(oldLines), changedLines[last]+diffContextLines+1)
+		fmt.Fprintf(w, "@@ -%d,%d +%d,%d @@\n", start+1, end-start, start+1, end-start)
+
+		changed := changedLines[first : last+1]
+		for i := start; i < end; {
+			if len(changed) == 0 || changed[0] != i {
+				writeDiffLine(w, " ", oldLines[i])
+				i += 1
+				continue
+			}
+
+			// a run of changed lines prints its old lines before its new lines
+			run := 1
+			for run < len(changed) && changed[run] == i+run {
+				run += 1
+			}
+			for j := i; j < i+run; j++ {
+				writeDiffLine(w, "-", oldLines[j])
+			}
+			for j := i; j < i+run; j++ {
+				writeDiffLine(w, "+", newLines[j])
+			}
+			changed = changed[run:]
+			i += run
+		}
+
+		first = last + 1
+	}
+}
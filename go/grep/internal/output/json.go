@@ -0,0 +1,169 @@
+package output
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/ikraduya/codingchallanges/go/grep/internal/match"
+)
+
+// Text of a path, a line or a match, the bytes are encoded in base64 when they are not valid UTF-8
+type jsonData struct {
+	Text  *string `json:"text,omitempty"`
+	Bytes *string `json:"bytes,omitempty"`
+}
+
+func newJSONData(s string) jsonData {
+	if utf8.ValidString(s) {
+		return jsonData{Text: &s}
+	}
+
+	encoded := base64.StdEncoding.EncodeToString([]byte(s))
+	return jsonData{Bytes: &encoded}
+}
+
+type jsonEvent struct {
+	Type string `json:"type"`
+	Data any    `json:"data"`
+}
+
+type jsonBegin struct {
+	Path jsonData `json:"path"`
+}
+
+// Start and End are byte offsets in the line
+type jsonSubmatch struct {
+	Match jsonData `json:"match"`
+	Start int      `json:"start"`
+	End   int      `json:"end"`
+}
+
+type jsonLine struct {
+	Path           jsonData       `json:"path"`
+	Lines          jsonData       `json:"lines"`
+	LineNumber     int            `json:"line_number"`
+	AbsoluteOffset int            `json:"absolute_offset"`
+	Submatches     []jsonSubmatch `json:"submatches"`
+}
+
+// Statistics of one input
+type JSONStats struct {
+	MatchedLines  int `json:"matched_lines"`
+	Matches       int `json:"matches"`
+	BytesSearched int `json:"bytes_searched"`
+}
+
+type jsonEnd struct {
+	Path  jsonData  `json:"path"`
+	Stats JSONStats `json:"stats"`
+}
+
+type jsonDuration struct {
+	Secs  int64  `json:"secs"`
+	Nanos int    `json:"nanos"`
+	Human string `json:"human"`
+}
+
+type jsonSummaryStats struct {
+	Searches          int `json:"searches"`
+	SearchesWithMatch int `json:"searches_with_match"`
+	JSONStats
+}
+
+type jsonSummary struct {
+	ElapsedTotal jsonDuration     `json:"elapsed_total"`
+	Stats        jsonSummaryStats `json:"stats"`
+}
+
+// Statistics of every input, added by the workers as they finish their files
+type JSONSummary struct {
+	mu    sync.Mutex
+	start time.Time
+	stats jsonSummaryStats
+}
+
+func NewJSONSummary() *JSONSummary {
+	return &JSONSummary{start: time.Now()}
+}
+
+func (summary *JSONSummary) Add(stats JSONStats) {
+	summary.mu.Lock()
+	defer summary.mu.Unlock()
+
+	summary.stats.Searches += 1
+	if stats.MatchedLines > 0 {
+		summary.stats.SearchesWithMatch += 1
+	}
+	summary.stats.MatchedLines += stats.MatchedLines
+	summary.stats.Matches += stats.Matches
+	summary.stats.BytesSearched += stats.BytesSearched
+}
+
+// The HTML characters of the lines are not escaped, every event ends with a newline
+func writeJSONEvent(w io.Writer, eventType string, data any) {
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	encoder.Encode(jsonEvent{Type: eventType, Data: data})
+}
+
+func OutputJSONBegin(w io.Writer, filepath string) {
+	writeJSONEvent(w, "begin", jsonBegin{Path: newJSONData(filepath)})
+}
+
+// Print a selected or a context line with its non-empty matches, return the number of matches
+func OutputJSONLine(w io.Writer, filepath string, lineString string, lineNumber int, byteOffset int, indexRanges []match.IndexRange, isContext bool) int {
+	// a rune of the matched line is either a valid rune or one invalid byte
+	runeOffsets := make([]int, 0, len(lineString)+1)
+	for i := 0; i < len(lineString); {
+		runeOffsets = append(runeOffsets, i)
+		_, size := utf8.DecodeRuneInString(lineString[i:])
+		i += size
+	}
+	runeOffsets = append(runeOffsets, len(lineString))
+
+	submatches := make([]jsonSubmatch, 0, len(indexRanges))
+	for _, indexRange := range indexRanges {
+		if indexRange.Start == indexRange.Stop {
+			continue
+		}
+		start, end := runeOffsets[indexRange.Start], runeOffsets[indexRange.Stop]
+		submatches = append(submatches, jsonSubmatch{Match: newJSONData(lineString[start:end]), Start: start, End: end})
+	}
+
+	eventType := "match"
+	if isContext {
+		eventType = "context"
+	}
+	writeJSONEvent(w, eventType, jsonLine{
+		Path:           newJSONData(filepath),
+		Lines:          newJSONData(lineString),
+		LineNumber:     lineNumber,
+		AbsoluteOffset: byteOffset,
+		Submatches:     submatches,
+	})
+
+	return len(submatches)
+}
+
+func OutputJSONEnd(w io.Writer, filepath string, stats JSONStats) {
+	writeJSONEvent(w, "end", jsonEnd{Path: newJSONData(filepath), Stats: stats})
+}
+
+func OutputJSONSummary(w io.Writer, summary *JSONSummary) {
+	summary.mu.Lock()
+	defer summary.mu.Unlock()
+
+	elapsed := time.Since(summary.start)
+	writeJSONEvent(w, "summary", jsonSummary{
+		ElapsedTotal: jsonDuration{
+			Secs:  int64(elapsed / time.Second),
+			Nanos: int(elapsed % time.Second),
+			Human: elapsed.String(),
+		},
+		Stats: summary.stats,
+	})
+}
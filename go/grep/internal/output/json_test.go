@@ -0,0 +1,56 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/ikraduya/codingchallanges/go/grep/internal/match"
+)
+
+func TestOutputJSONLine(t *testing.T) {
+	cases := []struct {
+		path        string
+		line        string
+		indexRanges []match.IndexRange
+		expected    string
+	}{
+		{
+			"a.txt", "héllo <b>", []match.IndexRange{{Start: 1, Stop: 3}, {Start: 4, Stop: 4}},
+			`{"type":"match","data":{"path":{"text":"a.txt"},"lines":{"text":"héllo <b>"},"line_number":3,` +
+				`"absolute_offset":10,"submatches":[{"match":{"text":"él"},"start":1,"end":4}]}}`,
+		},
+		{
+			// the invalid byte is one rune of the line
+			"a.txt", "a\xffbc", []match.IndexRange{{Start: 1, Stop: 2}, {Start: 2, Stop: 4}},
+			`{"type":"match","data":{"path":{"text":"a.txt"},"lines":{"bytes":"Yf9iYw=="},"line_number":3,` +
+				`"absolute_offset":10,"submatches":[{"match":{"bytes":"/w=="},"start":1,"end":2},` +
+				`{"match":{"text":"bc"},"start":2,"end":4}]}}`,
+		},
+		{
+			"\xfe.txt", "abc", nil,
+			`{"type":"match","data":{"path":{"bytes":"/i50eHQ="},"lines":{"text":"abc"},"line_number":3,` +
+				`"absolute_offset":10,"submatches":[]}}`,
+		},
+	}
+
+	for _, c := range cases {
+		var buf bytes.Buffer
+		matchCount := OutputJSONLine(&buf, c.path, c.line, 3, 10, c.indexRanges, false)
+
+		var output, expected any
+		if err := json.Unmarshal(buf.Bytes(), &output); err != nil {
+			t.Fatalf("%q: %v", c.line, err)
+		}
+		if err := json.Unmarshal([]byte(c.expected), &expected); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(output, expected) {
+			t.Errorf("%q: got %s, expected %s", c.line, buf.Bytes(), c.expected)
+		}
+		if expectedCount := len(expected.(map[string]any)["data"].(map[string]any)["submatches"].([]any)); matchCount != expectedCount {
+			t.Errorf("%q: got %d matches, expected %d", c.line, matchCount, expectedCount)
+		}
+	}
+}
@@ -2,65 +2,161 @@ package output
 
 import (
 	"fmt"
+	"io"
+	"strconv"
 	"strings"
 
-	"ccgrep/internal/match"
+	"github.com/ikraduya/codingchallanges/go/grep/internal/match"
+)
+
+type PrefixOption struct {
+	IsFilepathShown   bool
+	IsLineNumberShown bool
+	IsByteOffsetShown bool
+	IsColumnShown     bool
+
+	IsNullAfterFilepath bool // -Z, a NUL instead of the separator after the file name
+}
+
+// Location of a printed line, Option decides which fields are printed
+type LinePrefix struct {
+	Filepath   string
+	LineNumber int // 1-based
+	ByteOffset int // 0-based, of the line or of the match
+	Column     int // 1-based, 0 when there is no match to point at (e.g. inverted match)
+	IsContext  bool
 
-	"github.com/fatih/color"
+	Option PrefixOption
+}
+
+const (
+	matchSeparator   = ":"
+	contextSeparator = "-"
+	groupSeparator   = "--"
 )
 
-type TextColorTheme struct {
-	Prefix func(a ...interface{}) string
-	Match  func(a ...interface{}) string
-	Normal func(a ...interface{}) string
+func (prefix LinePrefix) format(textColorTheme TextColorTheme) string {
+	var sb strings.Builder
+
+	separator := matchSeparator
+	if prefix.IsContext {
+		separator = contextSeparator
+	}
+
+	if prefix.Option.IsFilepathShown {
+		sb.WriteString(textColorTheme.Filepath(prefix.Filepath))
+		if prefix.Option.IsNullAfterFilepath {
+			sb.WriteByte(0)
+		} else {
+			sb.WriteString(textColorTheme.Separator(separator))
+		}
+	}
+	if prefix.Option.IsLineNumberShown {
+		sb.WriteString(textColorTheme.LineNumber(strconv.Itoa(prefix.LineNumber)))
+		sb.WriteString(textColorTheme.Separator(separator))
+	}
+	if prefix.Option.IsColumnShown && prefix.Column > 0 {
+		sb.WriteString(textColorTheme.LineNumber(strconv.Itoa(prefix.Column)))
+		sb.WriteString(textColorTheme.Separator(separator))
+	}
+	if prefix.Option.IsByteOffsetShown {
+		sb.WriteString(textColorTheme.ByteOffset(strconv.Itoa(prefix.ByteOffset)))
+		sb.WriteString(textColorTheme.Separator(separator))
+	}
+
+	return sb.String()
 }
 
-func NormalTextColor() func(a ...interface{}) string {
-	return func(a ...interface{}) string { return fmt.Sprint(a...) }
+// Colors of the matches and of the rest of a selected or a context line
+func (textColorTheme TextColorTheme) lineColors(isContext bool) (ColorFunc, ColorFunc) {
+	if isContext {
+		return textColorTheme.ContextMatch, textColorTheme.ContextLine
+	}
+
+	return textColorTheme.SelectedMatch, textColorTheme.SelectedLine
 }
 
-func DefaultTextColorTheme() TextColorTheme {
-	return TextColorTheme{
-		Prefix: color.New(color.FgHiMagenta).SprintFunc(),
-		Match:  color.New(color.FgHiRed, color.Bold).SprintFunc(),
-		Normal: NormalTextColor(),
+// The line ending is kept out of the color, a terminal would otherwise color the next line
+func writeText(sb *strings.Builder, text string, textColor ColorFunc) {
+	body := strings.TrimSuffix(strings.TrimSuffix(strings.TrimSuffix(text, "\x00"), "\n"), "\r")
+	if body != "" {
+		sb.WriteString(textColor(body))
 	}
+	sb.WriteString(text[len(body):])
 }
 
-func OutputDefaultColor(lineString string, printPrefix string, textColorTheme TextColorTheme) {
+func OutputDefaultColor(w io.Writer, lineString string, prefix LinePrefix, textColorTheme TextColorTheme) {
 	var sb strings.Builder
 
-	if printPrefix != "" {
-		sb.WriteString(textColorTheme.Prefix(printPrefix))
-	}
-	sb.WriteString(textColorTheme.Normal(lineString))
+	_, lineColor := textColorTheme.lineColors(prefix.IsContext)
+	sb.WriteString(prefix.format(textColorTheme))
+	writeText(&sb, lineString, lineColor)
 
-	fmt.Print(sb.String())
+	io.WriteString(w, sb.String())
 }
 
-func Output(line []rune, indexRanges []match.IndexRange, printPrefix string, textColorTheme TextColorTheme) {
+// Empty matches (e.g. "x*") are not colored, it would only print escape codes
+func writeMatch(sb *strings.Builder, line []rune, indexRange match.IndexRange, matchColor ColorFunc) {
+	if indexRange.Start < indexRange.Stop {
+		sb.WriteString(matchColor(string(line[indexRange.Start:indexRange.Stop])))
+	}
+}
+
+func Output(w io.Writer, line []rune, indexRanges []match.IndexRange, prefix LinePrefix, textColorTheme TextColorTheme) {
 	var sb strings.Builder
 
-	if printPrefix != "" {
-		sb.WriteString(textColorTheme.Prefix(printPrefix))
-	}
+	matchColor, lineColor := textColorTheme.lineColors(prefix.IsContext)
+	sb.WriteString(prefix.format(textColorTheme))
 
-	if indexRanges[0].Start > 0 {
-		sb.WriteString(textColorTheme.Normal(string(line[:indexRanges[0].Start])))
-	}
+	writeText(&sb, string(line[:indexRanges[0].Start]), lineColor)
 	for i := 0; i < len(indexRanges)-1; i++ {
-		sb.WriteString(textColorTheme.Match(string(line[indexRanges[i].Start:indexRanges[i].Stop])))
-		sb.WriteString(textColorTheme.Normal(string(line[indexRanges[i].Stop:indexRanges[i+1].Start])))
+		writeMatch(&sb, line, indexRanges[i], matchColor)
+		writeText(&sb, string(line[indexRanges[i].Stop:indexRanges[i+1].Start]), lineColor)
 	}
 	lastRangeIndex := len(indexRanges) - 1
-	sb.WriteString(textColorTheme.Match(string(line[indexRanges[lastRangeIndex].Start:indexRanges[lastRangeIndex].Stop])))
-	if indexRanges[lastRangeIndex].Stop < len(line) {
-		sb.WriteString(textColorTheme.Normal(string(line[indexRanges[lastRangeIndex].Stop:])))
-	}
+	writeMatch(&sb, line, indexRanges[lastRangeIndex], matchColor)
+	writeText(&sb, string(line[indexRanges[lastRangeIndex].Stop:]), lineColor)
+
+	io.WriteString(w, sb.String())
+}
+
+// lineTerminator ends the row, '\n' or NUL with -z
+func OutputOnlyMatching(w io.Writer, line []rune, indexRange match.IndexRange, prefix LinePrefix, textColorTheme TextColorTheme, lineTerminator byte) {
+	var sb strings.Builder
+
+	matchColor, _ := textColorTheme.lineColors(prefix.IsContext)
+	sb.WriteString(prefix.format(textColorTheme))
+	writeMatch(&sb, line, indexRange, matchColor)
+	sb.WriteByte(lineTerminator)
 
-	fmt.Print(sb.String())
+	io.WriteString(w, sb.String())
+}
+
+// Terminate the last printed line when the input does not
+func OutputExtraLine(w io.Writer, lineTerminator byte) {
+	w.Write([]byte{lineTerminator})
+}
+
+// Separate two groups of lines that are not adjacent when context lines are printed
+func OutputGroupSeparator(w io.Writer, textColorTheme TextColorTheme) {
+	fmt.Fprintln(w, textColorTheme.Separator(groupSeparator))
+}
+
+func OutputCount(w io.Writer, filepath string, option PrefixOption, count int, textColorTheme TextColorTheme) {
+	prefix := LinePrefix{Filepath: filepath, Option: PrefixOption{IsFilepathShown: option.IsFilepathShown, IsNullAfterFilepath: option.IsNullAfterFilepath}}
+	fmt.Fprint(w, prefix.format(textColorTheme))
+	fmt.Fprintln(w, count)
+}
+
+// The file name of -l and -L ends with a newline, or a NUL with -Z
+func OutputFilepath(w io.Writer, filepath string, isNullTerminated bool, textColorTheme TextColorTheme) {
+	if isNullTerminated {
+		fmt.Fprint(w, textColorTheme.Filepath(filepath), "\x00")
+		return
+	}
+	fmt.Fprintln(w, textColorTheme.Filepath(filepath))
 }
 
-func OutputExtraLine() {
-	fmt.Println()
+func OutputBinaryFileMatches(w io.Writer, filepath string) {
+	fmt.Fprintf(w, "Binary file %s matches\n", filepath)
 }
@@ -0,0 +1,327 @@
+// Package trigram implements an on-disk index of the trigrams of the files of a directory tree, used to
+// narrow the files a search has to read to the ones that contain the trigrams of its required literals.
+package trigram
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// Name of the index file in the indexed directory
+const Filename = ".ccgrep-index"
+
+const formatVersion = 1
+
+// Larger files are left out of the index, so they are always searched
+const maxIndexedFileSize = 256 << 20
+
+// An indexed file, it is up to date as long as its size and modification time are the same
+type fileEntry struct {
+	Path       string // relative to the indexed directory, with slashes
+	Size       int64
+	ModTime    int64 // in nanoseconds since the epoch
+	IsNonASCII bool  // the folded trigrams of ASCII literals may miss its matches, e.g. "ß" for "ss"
+}
+
+// The encoded index: the posting list of a trigram is the sorted ids of its files as varint deltas. The
+// trigrams are taken on the bytes with the ASCII letters folded to lower case.
+type indexData struct {
+	Version  int
+	Files    []fileEntry // the id of a file is its position
+	Postings map[uint32][]byte
+}
+
+type Index struct {
+	dir    string
+	data   indexData
+	fileID map[string]int
+
+	candidates []bool // nil when every file may match
+}
+
+type BuildStats struct {
+	FileCount    int // files in the index
+	ReadCount    int // files that were new or changed
+	RemovedCount int // files of the previous index that are gone
+}
+
+func indexPath(dir string) string {
+	return filepath.Join(dir, Filename)
+}
+
+// Load the index of dir
+func Load(dir string) (*Index, error) {
+	fp, err := os.Open(indexPath(dir))
+	if err != nil {
+		return nil, err
+	}
+	defer fp.Close()
+
+	index := &Index{dir: dir}
+	if err := gob.NewDecoder(bufio.NewReader(fp)).Decode(&index.data); err != nil {
+		return nil, fmt.Errorf("%s: invalid index: %v", indexPath(dir), err)
+	}
+	if index.data.Version != formatVersion {
+		return nil, fmt.Errorf("%s: index version %d, expected %d", indexPath(dir), index.data.Version, formatVersion)
+	}
+
+	index.fileID = make(map[string]int, len(index.data.Files))
+	for id, file := range index.data.Files {
+		index.fileID[file.Path] = id
+	}
+	return index, nil
+}
+
+// Index the files of dir, given by their path relative to dir. The trigrams of a file of previous with the
+// same size and modification time are kept without reading it again.
+func Build(dir string, paths []string, previous *Index) (*Index, BuildStats) {
+	index := &Index{dir: dir, data: indexData{Version: formatVersion, Postings: make(map[uint32][]byte)}, fileID: make(map[string]int)}
+	var stats BuildStats
+
+	paths = slices.Clone(paths)
+	slices.Sort(paths)
+	previousIDs := make(map[int]int) // id in previous -> id in index, for the unchanged files
+	fileTrigrams := make(map[int][]uint32)
+	for _, path := range paths {
+		path = filepath.ToSlash(path)
+		if _, isFound := index.fileID[path]; isFound {
+			continue
+		}
+
+		info, err := os.Stat(filepath.Join(dir, path))
+		if err != nil || !info.Mode().IsRegular() || info.Size() > maxIndexedFileSize {
+			continue
+		}
+		entry := fileEntry{Path: path, Size: info.Size(), ModTime: info.ModTime().UnixNano()}
+		id := len(index.data.Files)
+
+		if previous != nil {
+			if previousID, isFound := previous.fileID[path]; isFound && previous.data.Files[previousID].isUpToDate(entry) {
+				index.data.Files = append(index.data.Files, previous.data.Files[previousID])
+				index.fileID[path] = id
+				previousIDs[previousID] = id
+				continue
+			}
+		}
+
+		trigrams, isNonASCII, err := readTrigrams(filepath.Join(dir, path))
+		if err != nil {
+			continue // e.g. removed since the walk, it is searched if it comes back
+		}
+		entry.IsNonASCII = isNonASCII
+		index.data.Files = append(index.data.Files, entry)
+		index.fileID[path] = id
+		fileTrigrams[id] = trigrams
+		stats.ReadCount++
+	}
+
+	postings := make(map[uint32][]int)
+	if previous != nil {
+		for trigram, list := range previous.data.Postings {
+			for _, previousID := range decodePostings(list) {
+				if id, isKept := previousIDs[previousID]; isKept {
+					postings[trigram] = append(postings[trigram], id)
+				}
+			}
+		}
+		for _, file := range previous.data.Files {
+			if _, isFound := index.fileID[file.Path]; !isFound {
+				stats.RemovedCount++
+			}
+		}
+	}
+	for id, trigrams := range fileTrigrams {
+		for _, trigram := range trigrams {
+			postings[trigram] = append(postings[trigram], id)
+		}
+	}
+	for trigram, ids := range postings {
+		slices.Sort(ids)
+		index.data.Postings[trigram] = encodePostings(ids)
+	}
+
+	stats.FileCount = len(index.data.Files)
+	return index, stats
+}
+
+// Write the index into its directory, through a temporary file so that a search never reads half of it
+func (index *Index) Save() error {
+	fp, err := os.CreateTemp(index.dir, Filename+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(fp.Name())
+
+	w := bufio.NewWriter(fp)
+	if err := gob.NewEncoder(w).Encode(&index.data); err != nil {
+		fp.Close()
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		fp.Close()
+		return err
+	}
+	if err := fp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(fp.Name(), indexPath(index.dir))
+}
+
+func (file fileEntry) isUpToDate(other fileEntry) bool {
+	return file.Size == other.Size && file.ModTime == other.ModTime
+}
+
+func foldByte(b byte) byte {
+	if 'A' <= b && b <= 'Z' {
+		return b + 'a' - 'A'
+	}
+	return b
+}
+
+// Return the distinct trigrams of a file and whether it has a byte outside ASCII
+func readTrigrams(path string) ([]uint32, bool, error) {
+	fp, err := os.Open(path)
+	if err != nil {
+		return nil, false, err
+	}
+	defer fp.Close()
+
+	seen := make(map[uint32]struct{})
+	isNonASCII := false
+	var trigram uint32
+	length := 0
+	r := bufio.NewReaderSize(fp, 64*1024)
+	for {
+		b, err := r.ReadByte()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, false, err
+		}
+
+		isNonASCII = isNonASCII || b >= 0x80
+		trigram = (trigram<<8 | uint32(foldByte(b))) & 0xFFFFFF
+		length++
+		if length >= 3 {
+			seen[trigram] = struct{}{}
+		}
+	}
+
+	trigrams := make([]uint32, 0, len(seen))
+	for trigram := range seen {
+		trigrams = append(trigrams, trigram)
+	}
+	return trigrams, isNonASCII, nil
+}
+
+func encodePostings(ids []int) []byte {
+	encoded := make([]byte, 0, len(ids))
+	previous := 0
+	for _, id := range ids {
+		encoded = binary.AppendUvarint(encoded, uint64(id-previous))
+		previous = id
+	}
+	return encoded
+}
+
+func decodePostings(encoded []byte) []int {
+	ids := make([]int, 0, len(encoded))
+	previous := 0
+	for len(encoded) > 0 {
+		delta, n := binary.Uvarint(encoded)
+		if n <= 0 {
+			break
+		}
+		previous += int(delta)
+		ids = append(ids, previous)
+		encoded = encoded[n:]
+	}
+	return ids
+}
+
+// Keep as candidates the files that contain every trigram of one of the literals, each matching line
+// contains one of them. With isFolded the literals match case insensitively: their trigrams with a byte
+// outside ASCII are not looked up, and the files with such bytes stay candidates. A literal without a
+// trigram to look up keeps every file.
+func (index *Index) SelectCandidates(literals [][]byte, isFolded bool) {
+	candidates := make([]bool, len(index.data.Files))
+	for _, literal := range literals {
+		ids, isNarrowed := index.literalFiles(literal, isFolded)
+		if !isNarrowed {
+			index.candidates = nil
+			return
+		}
+		for _, id := range ids {
+			candidates[id] = true
+		}
+	}
+	if isFolded {
+		for id, file := range index.data.Files {
+			candidates[id] = candidates[id] || file.IsNonASCII
+		}
+	}
+
+	index.candidates = candidates
+}
+
+// Return the ids of the files that have every trigram of literal, or false when it has no trigram to look up
+func (index *Index) literalFiles(literal []byte, isFolded bool) ([]int, bool) {
+	var ids []int
+	isNarrowed := false
+	for i := 0; i+3 <= len(literal); i++ {
+		if isFolded && (literal[i] >= 0x80 || literal[i+1] >= 0x80 || literal[i+2] >= 0x80) {
+			continue
+		}
+		trigram := uint32(foldByte(literal[i]))<<16 | uint32(foldByte(literal[i+1]))<<8 | uint32(foldByte(literal[i+2]))
+		list := decodePostings(index.data.Postings[trigram])
+		if !isNarrowed {
+			ids, isNarrowed = list, true
+		} else {
+			ids = intersect(ids, list)
+		}
+		if len(ids) == 0 {
+			break
+		}
+	}
+
+	return ids, isNarrowed
+}
+
+func intersect(a []int, b []int) []int {
+	result := a[:0]
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case a[i] < b[j]:
+			i++
+		case a[i] > b[j]:
+			j++
+		default:
+			result = append(result, a[i])
+			i++
+			j++
+		}
+	}
+	return result
+}
+
+// Return true when the file at path, relative to the indexed directory, cannot match: it is up to date in
+// the index and not a candidate. The files that are new or changed since the index was built are searched.
+func (index *Index) IsSkipped(path string, info os.FileInfo) bool {
+	if index.candidates == nil {
+		return false
+	}
+	id, isFound := index.fileID[filepath.ToSlash(path)]
+	if !isFound || index.candidates[id] {
+		return false
+	}
+
+	return index.data.Files[id].isUpToDate(fileEntry{Size: info.Size(), ModTime: info.ModTime().UnixNano()})
+}
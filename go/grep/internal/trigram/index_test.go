@@ -0,0 +1,126 @@
+package trigram
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, dir string, path string, content string, modTime time.Time) {
+	t.Helper()
+	fullPath := filepath.Join(dir, filepath.FromSlash(path))
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(fullPath, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(fullPath, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Return the files that are not skipped for the literals
+func searchedFiles(t *testing.T, index *Index, literals []string, isFolded bool) []string {
+	t.Helper()
+	literalBytes := make([][]byte, len(literals))
+	for i, literal := range literals {
+		literalBytes[i] = []byte(literal)
+	}
+	index.SelectCandidates(literalBytes, isFolded)
+
+	var paths []string
+	for _, file := range []string{"a.txt", "b.txt", "sub/c.txt", "d.txt"} {
+		info, err := os.Stat(filepath.Join(index.dir, filepath.FromSlash(file)))
+		if err != nil {
+			continue
+		}
+		if !index.IsSkipped(filepath.FromSlash(file), info) {
+			paths = append(paths, file)
+		}
+	}
+	return paths
+}
+
+func TestIsSkipped(t *testing.T) {
+	dir := t.TempDir()
+	modTime := time.Unix(1_700_000_000, 0)
+	writeFile(t, dir, "a.txt", "hello world\n", modTime)
+	writeFile(t, dir, "b.txt", "HELLO there\n", modTime)
+	writeFile(t, dir, "sub/c.txt", "straße\n", modTime)
+
+	built, stats := Build(dir, []string{"a.txt", "b.txt", "sub/c.txt"}, nil)
+	if stats != (BuildStats{FileCount: 3, ReadCount: 3}) {
+		t.Fatalf("got %+v", stats)
+	}
+	if err := built.Save(); err != nil {
+		t.Fatal(err)
+	}
+	index, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		literals []string
+		isFolded bool
+		expected []string
+	}{
+		{[]string{"hello"}, false, []string{"a.txt", "b.txt"}}, // the trigrams are folded
+		{[]string{"world"}, false, []string{"a.txt"}},
+		{[]string{"hello"}, true, []string{"a.txt", "b.txt", "sub/c.txt"}}, // c.txt is not ASCII
+		{[]string{"world", "there"}, false, []string{"a.txt", "b.txt"}},
+		{[]string{"missing"}, false, nil},
+		{[]string{"missing", "hi"}, false, []string{"a.txt", "b.txt", "sub/c.txt"}}, // "hi" has no trigram
+		{[]string{"straße"}, false, []string{"sub/c.txt"}},
+	}
+	for _, c := range cases {
+		if paths := searchedFiles(t, index, c.literals, c.isFolded); !slices.Equal(paths, c.expected) {
+			t.Errorf("%q (folded %v): got %q, expected %q", c.literals, c.isFolded, paths, c.expected)
+		}
+	}
+
+	// a change since the index was built is searched
+	writeFile(t, dir, "b.txt", "goodbye\n", modTime.Add(time.Second))
+	writeFile(t, dir, "d.txt", "goodbye\n", modTime)
+	if paths := searchedFiles(t, index, []string{"goodbye"}, false); !slices.Equal(paths, []string{"b.txt", "d.txt"}) {
+		t.Errorf("after a change: got %q", paths)
+	}
+}
+
+func TestBuildIncremental(t *testing.T) {
+	dir := t.TempDir()
+	modTime := time.Unix(1_700_000_000, 0)
+	writeFile(t, dir, "a.txt", "alpha\n", modTime)
+	writeFile(t, dir, "b.txt", "beta\n", modTime)
+	writeFile(t, dir, "sub/c.txt", "gamma\n", modTime)
+	previous, _ := Build(dir, []string{"a.txt", "b.txt", "sub/c.txt"}, nil)
+
+	writeFile(t, dir, "b.txt", "delta\n", modTime.Add(time.Second))
+	writeFile(t, dir, "d.txt", "alpha delta\n", modTime)
+	if err := os.Remove(filepath.Join(dir, "sub", "c.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	index, stats := Build(dir, []string{"d.txt", "b.txt", "a.txt"}, previous)
+	if stats != (BuildStats{FileCount: 3, ReadCount: 2, RemovedCount: 1}) {
+		t.Errorf("got %+v", stats)
+	}
+
+	cases := []struct {
+		literal  string
+		expected []string
+	}{
+		{"alpha", []string{"a.txt", "d.txt"}}, // a.txt kept from the previous index
+		{"delta", []string{"b.txt", "d.txt"}},
+		{"beta", nil},
+		{"gamma", nil},
+	}
+	for _, c := range cases {
+		if paths := searchedFiles(t, index, []string{c.literal}, false); !slices.Equal(paths, c.expected) {
+			t.Errorf("%q: got %q, expected %q", c.literal, paths, c.expected)
+		}
+	}
+}
@@ -1,6 +1,6 @@
 package kmp
 
-import "ccgrep/comparisonutils"
+import "github.com/ikraduya/codingchallanges/go/grep/comparisonutils"
 
 func ComputeLPSArray(pattern []rune, comparisonFunc comparisonutils.RuneComparisonFunc) []int {
 	pat_size := len(pattern)
@@ -28,3 +28,46 @@ func ComputeLPSArray(pattern []rune, comparisonFunc comparisonutils.RuneComparis
 
 	return lps
 }
+
+// Knuth-Morris-Pratt search of one pattern, the LPS array is computed once
+type Searcher struct {
+	pattern        []rune
+	lps            []int
+	comparisonFunc comparisonutils.RuneComparisonFunc
+}
+
+func NewSearcher(pattern []rune, comparisonFunc comparisonutils.RuneComparisonFunc) *Searcher {
+	return &Searcher{pattern: pattern, lps: ComputeLPSArray(pattern, comparisonFunc), comparisonFunc: comparisonFunc}
+}
+
+// Return the index of the first occurrence of the pattern in s at or after start, or -1
+func (searcher *Searcher) Index(s []rune, start int) int {
+	patLen := len(searcher.pattern)
+	if patLen == 0 {
+		return start
+	}
+
+	sIdx := start
+	patIdx := 0
+	for sIdx < len(s) {
+		// if character match, move pointer forward
+		if searcher.comparisonFunc(s[sIdx], searcher.pattern[patIdx]) {
+			sIdx += 1
+			patIdx += 1
+
+			// entire pattern is match
+			if patIdx == patLen {
+				return sIdx - patLen
+			}
+		} else {
+			// use lps of previous index
+			if patIdx != 0 {
+				patIdx = searcher.lps[patIdx-1]
+			} else {
+				sIdx += 1
+			}
+		}
+	}
+
+	return -1
+}
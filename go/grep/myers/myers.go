@@ -0,0 +1,208 @@
+package myers
+
+import "github.com/ikraduya/codingchallanges/go/grep/comparisonutils"
+
+// Longest pattern of the bit-vector algorithm, one bit per pattern rune
+const wordSize = 64
+
+// Approximate search of one pattern: the substrings of the text within maxDistance edits (insertion,
+// deletion or substitution of a rune) of the pattern. Myers' bit-vector algorithm updates a whole column
+// of the edit distance matrix per rune of the text with a few word operations, a pattern longer than a
+// word falls back to the dynamic programming of Sellers.
+type Searcher struct {
+	pattern        []rune
+	maxDistance    int
+	comparisonFunc comparisonutils.RuneComparisonFunc
+	foldFunc       comparisonutils.RuneFoldFunc
+
+	// bit i is set when the rune equals the pattern rune i
+	asciiPeq [128]uint64
+	peq      map[rune]uint64
+}
+
+func NewSearcher(pattern []rune, maxDistance int, comparisonFunc comparisonutils.RuneComparisonFunc, foldFunc comparisonutils.RuneFoldFunc) *Searcher {
+	searcher := &Searcher{pattern: pattern, maxDistance: maxDistance, comparisonFunc: comparisonFunc, foldFunc: foldFunc, peq: make(map[rune]uint64)}
+	if len(pattern) > wordSize {
+		return searcher
+	}
+
+	for i, r := range pattern {
+		r = foldFunc(r)
+		if r >= 0 && r < 128 {
+			searcher.asciiPeq[r] |= 1 << i
+		} else {
+			searcher.peq[r] |= 1 << i
+		}
+	}
+
+	return searcher
+}
+
+func (searcher *Searcher) equalities(r rune) uint64 {
+	r = searcher.foldFunc(r)
+	if r >= 0 && r < 128 {
+		return searcher.asciiPeq[r]
+	}
+
+	return searcher.peq[r]
+}
+
+// Distance between the pattern and the best substring of the text ending at each rune, fed one rune
+// at a time
+type columnScanner interface {
+	next(r rune) int
+}
+
+type bitVectorScanner struct {
+	searcher *Searcher
+	pv, mv   uint64 // vertical deltas of the column, +1 and -1
+	high     uint64 // bit of the last pattern rune
+	score    int
+}
+
+func (scanner *bitVectorScanner) next(r rune) int {
+	eq := scanner.searcher.equalities(r)
+	xv := eq | scanner.mv
+	xh := (((eq & scanner.pv) + scanner.pv) ^ scanner.pv) | eq
+	ph := scanner.mv | ^(xh | scanner.pv)
+	mh := scanner.pv & xh
+
+	if ph&scanner.high != 0 {
+		scanner.score += 1
+	} else if mh&scanner.high != 0 {
+		scanner.score -= 1
+	}
+
+	// the first row stays 0, a match may start anywhere in the text
+	ph <<= 1
+	mh <<= 1
+	scanner.pv = mh | ^(xv | ph)
+	scanner.mv = ph & xv
+	return scanner.score
+}
+
+type dynamicScanner struct {
+	searcher *Searcher
+	column   []int
+}
+
+func (scanner *dynamicScanner) next(r rune) int {
+	pattern := scanner.searcher.pattern
+	diagonal := scanner.column[0] // the first row stays 0
+	for i := 1; i <= len(pattern); i++ {
+		cost := 1
+		if scanner.searcher.comparisonFunc(r, pattern[i-1]) {
+			cost = 0
+		}
+		above := scanner.column[i]
+		scanner.column[i] = min(diagonal+cost, above+1, scanner.column[i-1]+1)
+		diagonal = above
+	}
+
+	return scanner.column[len(pattern)]
+}
+
+func (searcher *Searcher) newScanner() columnScanner {
+	patLen := len(searcher.pattern)
+	if patLen > wordSize {
+		column := make([]int, patLen+1)
+		for i := range column {
+			column[i] = i
+		}
+		return &dynamicScanner{searcher: searcher, column: column}
+	}
+
+	return &bitVectorScanner{searcher: searcher, pv: ^uint64(0) >> (wordSize - patLen), high: 1 << (patLen - 1), score: patLen}
+}
+
+// Return the first approximate match at or after start as [matchStart, matchStop), or -1, -1. Of the
+// ends within the distance that follow each other, the one with the lowest distance is kept.
+func (searcher *Searcher) Index(s []rune, start int) (int, int) {
+	scanner := searcher.newScanner()
+
+	bestStop, bestDistance := -1, 0
+	for j := start; j < len(s); j++ {
+		distance := scanner.next(s[j])
+		if distance <= searcher.maxDistance {
+			if bestStop < 0 || distance <= bestDistance {
+				bestStop, bestDistance = j+1, distance
+			}
+			continue
+		}
+		if bestStop >= 0 {
+			break
+		}
+	}
+	if bestStop < 0 {
+		return -1, -1
+	}
+
+	return searcher.matchStart(s, start, bestStop), bestStop
+}
+
+// Find where the match ending at stop starts with the dynamic programming of the reversed pattern against
+// the reversed text, the length closest to the one of the pattern wins among the lowest distances
+func (searcher *Searcher) matchStart(s []rune, start int, stop int) int {
+	pattern := searcher.pattern
+	windowLen := min(stop-start, len(pattern)+searcher.maxDistance)
+
+	// column[i] is the distance between the last i runes of the pattern and the last l runes of the window
+	column := make([]int, len(pattern)+1)
+	for i := range column {
+		column[i] = i
+	}
+
+	bestLen, bestDistance := 0, column[len(pattern)]
+	for l := 1; l <= windowLen; l++ {
+		r := s[stop-l]
+		diagonal := column[0]
+		column[0] = l
+		for i := 1; i <= len(pattern); i++ {
+			cost := 1
+			if searcher.comparisonFunc(r, pattern[len(pattern)-i]) {
+				cost = 0
+			}
+			above := column[i]
+			column[i] = min(diagonal+cost, above+1, column[i-1]+1)
+			diagonal = above
+		}
+
+		distance := column[len(pattern)]
+		if distance < bestDistance || (distance == bestDistance && abs(l-len(pattern)) <= abs(bestLen-len(pattern))) {
+			bestLen, bestDistance = l, distance
+		}
+	}
+
+	return stop - bestLen
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// Levenshtein distance between a and b
+func Distance(a []rune, b []rune, comparisonFunc comparisonutils.RuneComparisonFunc) int {
+	column := make([]int, len(a)+1)
+	for i := range column {
+		column[i] = i
+	}
+
+	for j := 1; j <= len(b); j++ {
+		diagonal := column[0]
+		column[0] = j
+		for i := 1; i <= len(a); i++ {
+			cost := 1
+			if comparisonFunc(a[i-1], b[j-1]) {
+				cost = 0
+			}
+			above := column[i]
+			column[i] = min(diagonal+cost, above+1, column[i-1]+1)
+			diagonal = above
+		}
+	}
+
+	return column[len(a)]
+}
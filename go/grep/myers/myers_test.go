@@ -0,0 +1,138 @@
+package myers
+
+import (
+	"math/rand"
+	"slices"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/ikraduya/codingchallanges/go/grep/comparisonutils"
+)
+
+// Few runes make many approximate matches, the last alphabets need the case folding of non ASCII runes
+var textAlphabets = []string{"ab", "acgt", "ab cd", "aAbB", "kKKsSſ", "é€😀éE"}
+
+// Half of the patterns are a substring of the text changed by at most maxDistance random edits, so that
+// they are found, the others are random runes
+func randomCase(rng *rand.Rand) ([]rune, []rune, int, bool) {
+	alphabet := []rune(textAlphabets[rng.Intn(len(textAlphabets))])
+	randomRune := func() rune {
+		return alphabet[rng.Intn(len(alphabet))]
+	}
+
+	s := make([]rune, rng.Intn(40))
+	for i := range s {
+		s[i] = randomRune()
+	}
+
+	patternLen := 1 + rng.Intn(12)
+	maxDistance := rng.Intn(min(3, patternLen))
+	isCaseInsensitive := rng.Intn(2) == 0
+	if patternLen > len(s) || rng.Intn(2) == 0 {
+		pattern := make([]rune, patternLen)
+		for i := range pattern {
+			pattern[i] = randomRune()
+		}
+		return s, pattern, maxDistance, isCaseInsensitive
+	}
+
+	start := rng.Intn(len(s) - patternLen + 1)
+	pattern := slices.Clone(s[start : start+patternLen])
+	for range rng.Intn(maxDistance + 1) {
+		i := rng.Intn(len(pattern))
+		switch rng.Intn(3) {
+		case 0:
+			pattern[i] = randomRune()
+		case 1:
+			pattern = slices.Insert(pattern, i, randomRune())
+		case 2:
+			if len(pattern) > 1 {
+				pattern = slices.Delete(pattern, i, i+1)
+			}
+		}
+	}
+
+	return s, pattern, maxDistance, isCaseInsensitive
+}
+
+// Whether a substring of s is within maxDistance edits of the pattern
+func naiveHasMatch(s []rune, pattern []rune, maxDistance int, equals comparisonutils.RuneComparisonFunc) bool {
+	for i := 0; i <= len(s); i++ {
+		for j := i; j <= len(s); j++ {
+			if Distance(pattern, s[i:j], equals) <= maxDistance {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// The searcher finds a match exactly when a substring is within the distance, and its match is one
+func FuzzSearcher(f *testing.F) {
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 1000; i++ {
+		s, pattern, maxDistance, isCaseInsensitive := randomCase(rng)
+		f.Add(string(s), string(pattern), maxDistance, isCaseInsensitive)
+	}
+	f.Add("ab", strings.Repeat("ab", 40), 2, false) // longer than a word, searched by the dynamic programming
+
+	f.Fuzz(func(t *testing.T, text string, patternText string, maxDistance int, isCaseInsensitive bool) {
+		s, pattern := []rune(text), []rune(patternText)
+		// the naive search is cubic
+		if !utf8.ValidString(text) || !utf8.ValidString(patternText) || len(s) > 40 || len(pattern) == 0 || len(pattern) > 2*wordSize {
+			t.Skip()
+		}
+		maxDistance = (maxDistance%len(pattern) + len(pattern)) % len(pattern)
+
+		equals, fold := comparisonutils.AreRunesCaseSensitiveEqual, comparisonutils.FoldRuneCaseSensitive
+		if isCaseInsensitive {
+			equals, fold = comparisonutils.AreRunesCaseInsensitiveEqual, comparisonutils.FoldRuneCaseInsensitive
+		}
+
+		start, stop := NewSearcher(pattern, maxDistance, equals, fold).Index(s, 0)
+		isExpected := naiveHasMatch(s, pattern, maxDistance, equals)
+		isValid := start < 0 || Distance(pattern, s[start:stop], equals) <= maxDistance
+		if (start >= 0) != isExpected || !isValid {
+			t.Fatalf("pattern %q within %d in %q (case insensitive: %v)\n\tfound [%d, %d), expected a match: %v",
+				patternText, maxDistance, text, isCaseInsensitive, start, stop, isExpected)
+		}
+	})
+}
+
+func BenchmarkSearcher(b *testing.B) {
+	rng := rand.New(rand.NewSource(1))
+	words := strings.Fields("the quick brown fox jumps over lazy dog grep pattern search line match")
+	var sb strings.Builder
+	for sb.Len() < 1024*1024 {
+		sb.WriteString(words[rng.Intn(len(words))])
+		sb.WriteString(" ")
+	}
+	text := []rune(sb.String())
+
+	cases := []struct {
+		name        string
+		pattern     string
+		maxDistance int
+	}{
+		{"short", "fxo", 1},
+		{"medium", "jumps ovr thr", 2},
+		{"long", strings.Repeat("the lazy dog was not there ", 3), 3}, // longer than a word
+	}
+	for _, c := range cases {
+		b.Run(c.name, func(b *testing.B) {
+			searcher := NewSearcher([]rune(c.pattern), c.maxDistance, comparisonutils.AreRunesCaseSensitiveEqual, comparisonutils.FoldRuneCaseSensitive)
+			for b.Loop() {
+				for start := 0; start < len(text); {
+					_, stop := searcher.Index(text, start)
+					if stop < 0 {
+						break
+					}
+					start = max(stop, start+1)
+				}
+			}
+			b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N)/float64(len(text)), "ns/rune")
+		})
+	}
+}
@@ -0,0 +1,198 @@
+package search
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+)
+
+// Separates the path of an archive and the path of a member, e.g. "logs.zip:app/error.log"
+const archiveMemberSeparator = ":"
+
+type archiveKind int
+
+const (
+	archiveNone archiveKind = iota
+	archiveZip
+	archiveTar
+	archiveTarGzip
+)
+
+func archiveKindOf(name string) archiveKind {
+	name = strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(name, ".zip"):
+		return archiveZip
+	case strings.HasSuffix(name, ".tar"):
+		return archiveTar
+	case strings.HasSuffix(name, ".tar.gz") || strings.HasSuffix(name, ".tgz"):
+		return archiveTarGzip
+	}
+
+	return archiveNone
+}
+
+// Largest nested zip archive that is opened, it needs random access and is held whole in memory. A larger one
+// is reported as an error of its member, the nested tar archives are read as streams.
+const MaxNestedZipSize = 256 << 20
+
+// Err of a nested zip archive larger than MaxNestedZipSize, the other members are still searched
+var ErrArchiveTooLarge = errors.New("nested zip archive too large")
+
+// The members of the archives of one job, a result each like the files of the walk
+type archiveSearch struct {
+	searcher *Searcher
+	fn       InputFunc
+	results  []inputResult
+}
+
+// Search the members of an archive file. The error of a member is its result and the search goes on
+// with the next member, an error that stops the search of the archive is the last result.
+func (searcher *Searcher) searchArchiveFile(filepath string, fn InputFunc) []inputResult {
+	search := &archiveSearch{searcher: searcher, fn: fn, results: make([]inputResult, 0)}
+	if err := search.searchFile(filepath); err != nil {
+		search.results = append(search.results, unsearchedInput(filepath, err, fn))
+	}
+	return search.results
+}
+
+func (search *archiveSearch) searchFile(filepath string) error {
+	fp, err := os.Open(filepath)
+	if err != nil {
+		return err
+	}
+	defer fp.Close()
+
+	if kind := archiveKindOf(filepath); kind != archiveZip {
+		return search.searchTar(filepath, kind, fp, 1)
+	}
+
+	info, err := fp.Stat()
+	if err != nil {
+		return err
+	}
+	return search.searchZip(filepath, fp, info.Size(), 1)
+}
+
+// depth is the nesting level of the archive, 1 for an archive of the walk
+func (search *archiveSearch) searchZip(name string, r io.ReaderAt, size int64, depth int) error {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return err
+	}
+
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		member, err := f.Open()
+		if err != nil {
+			search.addMemberError(name, f.Name, err)
+			continue
+		}
+		search.searchMember(name, f.Name, member, depth)
+		member.Close()
+	}
+	return nil
+}
+
+// A broken stream cannot be read past, its error stops the search of the archive
+func (search *archiveSearch) searchTar(name string, kind archiveKind, r io.Reader, depth int) error {
+	if kind == archiveTarGzip {
+		gr, err := gzip.NewReader(r)
+		if err != nil {
+			return err
+		}
+		r = gr
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if header.Typeflag == tar.TypeReg {
+			search.searchMember(name, header.Name, tr, depth)
+		}
+	}
+}
+
+func memberDisplayPath(archiveName string, memberPath string) string {
+	return archiveName + archiveMemberSeparator + path.Clean(memberPath) // e.g. "./a.log" of tar
+}
+
+func (search *archiveSearch) addMemberError(archiveName string, memberPath string, err error) {
+	search.results = append(search.results, unsearchedInput(memberDisplayPath(archiveName, memberPath), err, search.fn))
+}
+
+// Whether the walk would leave out a file at the path of a member: a hidden file or directory, or a
+// directory of ExcludeDirs on the path
+func (option WalkOption) isMemberSkipped(memberPath string) bool {
+	names := strings.Split(path.Clean(memberPath), "/")
+	for i, name := range names {
+		if !option.IsHiddenShown && strings.HasPrefix(name, ".") && name != "." && name != ".." {
+			return true
+		}
+		if i < len(names)-1 && isGlobMatched(option.ExcludeDirs, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Search a member like a file of the walk, or descend into it when it is an archive within the depth limit
+func (search *archiveSearch) searchMember(archiveName string, memberPath string, member io.Reader, depth int) {
+	option := search.searcher.option.Walk
+	displayPath := memberDisplayPath(archiveName, memberPath)
+	memberName := path.Base(memberPath)
+	if option.isMemberSkipped(memberPath) {
+		return
+	}
+
+	if kind := archiveKindOf(memberName); kind != archiveNone && depth < option.MaxArchiveDepth {
+		if isGlobMatched(option.Excludes, memberName) {
+			return
+		}
+		if err := search.searchNestedArchive(displayPath, kind, member, depth+1); err != nil {
+			search.addMemberError(archiveName, memberPath, err)
+		}
+		return
+	}
+
+	if !option.isFileIncluded(memberName) {
+		return
+	}
+
+	// the lines read before an error were delivered
+	search.results = append(search.results, searchInput(displayPath, search.fn, func(result *Result, lineFn LineFunc) error {
+		return search.searcher.searchReader(member, result, lineFn)
+	}))
+}
+
+func (search *archiveSearch) searchNestedArchive(name string, kind archiveKind, member io.Reader, depth int) error {
+	if kind != archiveZip {
+		return search.searchTar(name, kind, member, depth)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(member, MaxNestedZipSize+1))
+	if err != nil {
+		return err
+	}
+	if len(data) > MaxNestedZipSize {
+		return fmt.Errorf("%w (more than %d bytes)", ErrArchiveTooLarge, MaxNestedZipSize)
+	}
+	return search.searchZip(name, bytes.NewReader(data), int64(len(data)), depth)
+}
@@ -0,0 +1,118 @@
+package search
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+type archiveMember struct {
+	name    string
+	content string
+}
+
+func newTarGzip(t *testing.T, members []archiveMember) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+	for _, member := range members {
+		header := &tar.Header{Name: member.name, Mode: 0o644, Size: int64(len(member.content)), Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(member.content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func writeZip(t *testing.T, path string, members []archiveMember) {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, member := range members {
+		w, err := zw.Create(member.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(member.content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSearchTreeArchives(t *testing.T) {
+	root := t.TempDir()
+	inner := newTarGzip(t, []archiveMember{{"./f.log", "Nirvana\nNirvana\n"}})
+	writeZip(t, filepath.Join(root, "logs.zip"), []archiveMember{
+		{"a.log", "Nirvana\n"},
+		{".hidden/c.log", "Nirvana\n"},
+		{"vendor/e.log", "Nirvana\n"},
+		{"inner.tar.gz", string(inner)},
+		{"broken.tar", "junk"},
+		{"g.txt", "Kiss\n"},
+	})
+
+	cases := []struct {
+		name     string
+		change   func(option *WalkOption)
+		expected []string
+	}{
+		{"members", func(option *WalkOption) {},
+			[]string{"a.log 1", "vendor/e.log 1", "inner.tar.gz:f.log 2", "broken.tar error", "g.txt 0"}},
+		{"hidden", func(option *WalkOption) { option.IsHiddenShown = true },
+			[]string{"a.log 1", ".hidden/c.log 1", "vendor/e.log 1", "inner.tar.gz:f.log 2", "broken.tar error", "g.txt 0"}},
+		{"exclude dirs", func(option *WalkOption) { option.ExcludeDirs = []string{"vend*"} },
+			[]string{"a.log 1", "inner.tar.gz:f.log 2", "broken.tar error", "g.txt 0"}},
+		{"includes", func(option *WalkOption) { option.Includes = []string{"*.log"} },
+			[]string{"a.log 1", "vendor/e.log 1", "inner.tar.gz:f.log 2", "broken.tar error"}}, // the nested archives are opened
+		{"excludes", func(option *WalkOption) { option.Excludes = []string{"*.tar.gz"} },
+			[]string{"a.log 1", "vendor/e.log 1", "broken.tar error", "g.txt 0"}},
+		{"depth 1", func(option *WalkOption) { option.MaxArchiveDepth = 1 },
+			[]string{"a.log 1", "vendor/e.log 1", "inner.tar.gz 0", "broken.tar 0", "g.txt 0"}}, // searched as files
+	}
+
+	for _, c := range cases {
+		option := NewOption("Nirvana")
+		option.Walk.IsArchiveSearched = true
+		c.change(&option.Walk)
+		searcher, err := New(option)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		found := make([]string, 0)
+		for result := range searcher.Results([]string{root}) {
+			path := strings.TrimPrefix(result.Path, filepath.Join(root, "logs.zip")+archiveMemberSeparator)
+			if result.Err != nil {
+				found = append(found, path+" error")
+			} else {
+				found = append(found, fmt.Sprintf("%s %d", path, result.SelectedCount))
+			}
+		}
+		if !slices.Equal(found, c.expected) {
+			t.Errorf("%s: got %q, expected %q", c.name, found, c.expected)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package search
+
+import (
+	"bytes"
+	"unicode/utf8"
+)
+
+// How a binary input is searched, like --binary-files of GNU grep
+type BinaryFilesMode int
+
+const (
+	BinaryFilesBinary       BinaryFilesMode = iota // stop at the first selected line and deliver no line, "Binary file X matches"
+	BinaryFilesText                                // search it like a text input
+	BinaryFilesWithoutMatch                        // assume it does not match
+)
+
+// Size of the first block of an input that decides whether it is binary
+const binaryDetectionSize = 32 * 1024
+
+// A block is binary when it has a NUL byte that does not end a line (-z) or is not valid UTF-8
+func isBinaryBlock(block []byte, terminator byte) bool {
+	if terminator != 0 && bytes.IndexByte(block, 0) >= 0 {
+		return true
+	}
+
+	// the block may end in the middle of a rune that continues after it
+	for i := len(block) - 1; i >= 0 && i >= len(block)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(block[i]) {
+			if !utf8.FullRune(block[i:]) {
+				block = block[:i]
+			}
+			break
+		}
+	}
+
+	return !utf8.Valid(block)
+}
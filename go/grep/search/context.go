@@ -0,0 +1,48 @@
+package search
+
+// Ring buffer of the last lines that were not delivered, oldest lines are overwritten.
+// It grows as lines arrive until it holds size lines.
+type contextRingBuffer struct {
+	lines []Line
+	size  int
+	start int
+	count int
+}
+
+func newContextRingBuffer(size int) *contextRingBuffer {
+	return &contextRingBuffer{size: size}
+}
+
+func (rb *contextRingBuffer) push(line Line) {
+	if rb.size == 0 {
+		return
+	}
+
+	if rb.count < len(rb.lines) {
+		rb.lines[(rb.start+rb.count)%len(rb.lines)] = line
+		rb.count += 1
+		return
+	}
+
+	if len(rb.lines) < rb.size {
+		// The buffer is full only when start is 0, so the new line goes at the end
+		rb.lines = append(rb.lines, line)
+		rb.count += 1
+		return
+	}
+
+	rb.lines[rb.start] = line
+	rb.start = (rb.start + 1) % len(rb.lines)
+}
+
+// Return the buffered lines from oldest to newest and empty the buffer
+func (rb *contextRingBuffer) drain() []Line {
+	drained := make([]Line, 0, rb.count)
+	for i := 0; i < rb.count; i++ {
+		drained = append(drained, rb.lines[(rb.start+i)%len(rb.lines)])
+	}
+	rb.start = 0
+	rb.count = 0
+
+	return drained
+}
@@ -0,0 +1,76 @@
+package search
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+)
+
+// Return a reader of the decompressed data when r starts with the magic bytes of gzip (.gz), bzip2 (.bz2)
+// or zlib (.zz), r itself otherwise
+func newDecompressingReader(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReaderSize(r, binaryDetectionSize)
+	head, err := br.Peek(binaryDetectionSize)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	switch {
+	case isGzipBlock(head):
+		return gzip.NewReader(br)
+	case isBzip2Block(head):
+		return bzip2.NewReader(br), nil
+	case isZlibBlock(head):
+		return zlib.NewReader(br)
+	}
+
+	return br, nil
+}
+
+// Return true when block is the start of a file that newDecompressingReader decompresses
+func isCompressedBlock(block []byte) bool {
+	return isGzipBlock(block) || isBzip2Block(block) || isZlibBlock(block)
+}
+
+// A gzip member starts with its magic bytes, the deflate method and no reserved flag, and its block must
+// decompress like the one of isZlibBlock
+func isGzipBlock(block []byte) bool {
+	if len(block) < 10 || block[0] != 0x1f || block[1] != 0x8b || block[2] != 8 || block[3]&0xe0 != 0 {
+		return false
+	}
+
+	zr, err := gzip.NewReader(bytes.NewReader(block))
+	if err != nil {
+		return false
+	}
+	_, err = io.CopyN(io.Discard, zr, binaryDetectionSize)
+	return err == nil || err == io.EOF || err == io.ErrUnexpectedEOF
+}
+
+// "BZh" and the block size are also plain text like "BZh5 ...", the magic of the first block ("1AY&SY",
+// the BCD digits of pi) or of the end of an empty stream must follow
+func isBzip2Block(block []byte) bool {
+	if len(block) < 10 || !bytes.HasPrefix(block, []byte("BZh")) || block[3] < '1' || block[3] > '9' {
+		return false
+	}
+
+	magic := block[4:10]
+	return bytes.Equal(magic, []byte{0x31, 0x41, 0x59, 0x26, 0x53, 0x59}) || bytes.Equal(magic, []byte{0x17, 0x72, 0x45, 0x38, 0x50, 0x90})
+}
+
+// The two bytes of a zlib header are also plain text like "x^", the block must decompress too
+func isZlibBlock(block []byte) bool {
+	if len(block) < 2 || block[0]&0x0f != 8 || block[0]>>4 > 7 || (uint(block[0])<<8|uint(block[1]))%31 != 0 {
+		return false
+	}
+
+	zr, err := zlib.NewReader(bytes.NewReader(block))
+	if err != nil {
+		return false
+	}
+	_, err = io.CopyN(io.Discard, zr, binaryDetectionSize)
+	return err == nil || err == io.EOF || err == io.ErrUnexpectedEOF // the block may be the start of a longer stream
+}
@@ -0,0 +1,63 @@
+package search
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func compress(t *testing.T, newWriter func(w io.Writer) io.WriteCloser, content string) string {
+	t.Helper()
+	var buf bytes.Buffer
+	w := newWriter(&buf)
+	if _, err := io.WriteString(w, content); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func TestSearchReaderDecompressed(t *testing.T) {
+	content := "Nirvana\nKiss\nNirvana Bush\n"
+	gzipped := compress(t, func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) }, content)
+	zlibbed := compress(t, func(w io.Writer) io.WriteCloser { return zlib.NewWriter(w) }, content)
+	// "Nirvana\nKiss\n" compressed by bzip2, the package has no writer
+	bzipped := "BZh91AY&SY\x92\x58\xd4\xd2\x00\x00\x01\xc5\x80\x00\x10\x00\x09\x20\x21\x19\x00\x20\x00\x31\x06" +
+		"\x4c\x41\x00\x7a\x26\x2b\x81\x80\x95\x3f\x17\x72\x45\x38\x50\x90\x92\x58\xd4\xd2"
+
+	cases := []struct {
+		name     string
+		input    string
+		expected []string
+	}{
+		{"plain", content, []string{"1:Nirvana", "3:Nirvana Bush"}},
+		{"gzip", gzipped, []string{"1:Nirvana", "3:Nirvana Bush"}},
+		{"zlib", zlibbed, []string{"1:Nirvana", "3:Nirvana Bush"}},
+		{"bzip2", bzipped, []string{"1:Nirvana"}},
+		{"bzip2 magic as text", "BZh5 Nirvana\n", []string{"1:BZh5 Nirvana"}},
+		{"zlib header as text", "x^Nirvana\n", []string{"1:x^Nirvana"}},
+	}
+
+	for _, c := range cases {
+		option := NewOption("Nirvana")
+		option.IsZipSearched = true
+		if _, lines := searchLines(t, option, c.input); !slices.Equal(lines, c.expected) {
+			t.Errorf("%s: got %q, expected %q", c.name, lines, c.expected)
+		}
+	}
+}
+
+// Without IsZipSearched a compressed input is searched as is
+func TestSearchReaderCompressedAsIs(t *testing.T) {
+	content := strings.Repeat("Nirvana Kiss Queen\n", 50) // long enough to be compressed, not stored
+	gzipped := compress(t, func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) }, content)
+	if result, _ := searchLines(t, NewOption("Nirvana"), gzipped); result.SelectedCount != 0 || !result.IsBinary {
+		t.Errorf("got %d selected lines (binary %v), expected a binary input without a match", result.SelectedCount, result.IsBinary)
+	}
+}
@@ -0,0 +1,15 @@
+//go:build !unix
+
+package search
+
+import "os"
+
+// The owner of a file is not a uid and a gid, the rewritten file gets the default one
+func fileOwner(info os.FileInfo) (int, int, bool) {
+	return 0, 0, false
+}
+
+// A directory cannot be opened to be synced, the rename is as durable as the file system makes it
+func syncDir(dir string) error {
+	return nil
+}
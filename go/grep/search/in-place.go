@@ -0,0 +1,145 @@
+package search
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/ikraduya/codingchallanges/go/grep/internal/match"
+	"github.com/ikraduya/codingchallanges/go/grep/internal/output"
+)
+
+// Err of EditFile for a binary file, that is left as is unless searched as text
+var ErrBinaryNotEdited = errors.New("binary file matches, not edited")
+
+// Err of EditFile for a compressed file or an archive, that is left as is even when searched as text since
+// rewriting its bytes would corrupt it
+var ErrArchiveNotEdited = errors.New("compressed file or archive matches, not edited")
+
+// How EditFile rewrites a file with the replacements of Option.Replacement
+type EditOption struct {
+	BackupSuffix string // the original file is kept as FILE+SUFFIX when not empty
+	IsDryRun     bool   // write a unified diff instead of rewriting
+}
+
+// Split content after each terminator, the last line may have none
+func splitLines(content []byte, terminator byte) [][]byte {
+	lines := make([][]byte, 0)
+	for len(content) > 0 {
+		end := bytes.IndexByte(content, terminator) + 1
+		if end == 0 {
+			end = len(content)
+		}
+		lines = append(lines, content[:end])
+		content = content[end:]
+	}
+
+	return lines
+}
+
+// Write data to a temporary file of the same directory then rename it to path, so that path is either the
+// old or the new content, even after a crash since the data and then the directory are synced. The directory
+// was already read by the walker, the temporary files and the backups are not searched. path is not a
+// symbolic link, the mode and the owner of info are kept.
+func writeFileAtomically(path string, data []byte, info os.FileInfo) error {
+	fp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(fp.Name()) // fails once renamed
+
+	if _, err := fp.Write(data); err != nil {
+		fp.Close()
+		return err
+	}
+	if err := fp.Chmod(info.Mode()); err != nil {
+		fp.Close()
+		return err
+	}
+	if uid, gid, isKnown := fileOwner(info); isKnown {
+		fp.Chown(uid, gid) // only allowed to root or to change the group to one of the user
+	}
+	if err := fp.Sync(); err != nil {
+		fp.Close()
+		return err
+	}
+	if err := fp.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(fp.Name(), path); err != nil {
+		return err
+	}
+	return syncDir(filepath.Dir(path))
+}
+
+// Replace the matches of the selected lines of a file and rewrite it, or write the diff to w with
+// IsDryRun. Option.MaxCount limits the replaced lines, a binary file is left as is with ErrBinaryNotEdited
+// unless searched as text, a compressed file or an archive with ErrArchiveNotEdited. Return the number of
+// lines with a match.
+func (searcher *Searcher) EditFile(path string, option EditOption, w io.Writer) (selectedCount int, err error) {
+	defer match.CatchBacktrackLimit(&err)
+
+	if searcher.replacer == nil {
+		return 0, errors.New("editing a file requires Option.IsReplaced")
+	}
+
+	// a symbolic link stays one, its target is rewritten
+	target, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return 0, err
+	}
+	info, err := os.Stat(target)
+	if err != nil {
+		return 0, err
+	}
+	content, err := os.ReadFile(target)
+	if err != nil {
+		return 0, err
+	}
+	block := content[:min(len(content), binaryDetectionSize)]
+	if archiveKindOf(target) != archiveNone || isCompressedBlock(block) {
+		return 0, ErrArchiveNotEdited
+	}
+	if searcher.option.BinaryFiles != BinaryFilesText && isBinaryBlock(block, searcher.lineTerminator()) {
+		return 0, ErrBinaryNotEdited
+	}
+
+	maxCount := searcher.option.MaxCount
+	oldLines := splitLines(content, searcher.lineTerminator())
+	newLines := make([][]byte, len(oldLines))
+	changedLines := make([]int, 0)
+	for i, line := range oldLines {
+		newLines[i] = line
+		if maxCount >= 0 && selectedCount >= maxCount {
+			continue
+		}
+
+		edited, _, _ := searcher.replaceLine(line)
+		if edited == nil {
+			continue
+		}
+		selectedCount += 1
+		if !bytes.Equal(edited, line) {
+			newLines[i] = edited
+			changedLines = append(changedLines, i)
+		}
+	}
+
+	if option.IsDryRun {
+		output.OutputUnifiedDiff(w, path, oldLines, newLines, changedLines)
+		return selectedCount, nil
+	}
+	if len(changedLines) == 0 {
+		return selectedCount, nil
+	}
+
+	if option.BackupSuffix != "" {
+		if err := writeFileAtomically(target+option.BackupSuffix, content, info); err != nil {
+			return selectedCount, err
+		}
+	}
+	return selectedCount, writeFileAtomically(target, bytes.Join(newLines, nil), info)
+}
@@ -0,0 +1,178 @@
+package search
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newEditSearcher(t *testing.T, pattern string, replacement string) *Searcher {
+	t.Helper()
+	option := NewOption(pattern)
+	option.IsReplaced, option.Replacement = true, replacement
+	searcher, err := New(option)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return searcher
+}
+
+func writeTestFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "bands.txt")
+	if err := os.WriteFile(path, []byte(content), 0o640); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func readTestFile(t *testing.T, path string) string {
+	t.Helper()
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(content)
+}
+
+func TestEditFile(t *testing.T) {
+	searcher := newEditSearcher(t, "Nirvana", "Foo Fighters")
+	path := writeTestFile(t, "Nirvana\nKiss\nNirvana Nirvana")
+
+	selectedCount, err := searcher.EditFile(path, EditOption{BackupSuffix: ".orig"}, nil)
+	if err != nil || selectedCount != 2 {
+		t.Fatalf("got %d selected lines and error %v, expected 2", selectedCount, err)
+	}
+	if content, expected := readTestFile(t, path), "Foo Fighters\nKiss\nFoo Fighters Foo Fighters"; content != expected {
+		t.Errorf("got %q, expected %q", content, expected)
+	}
+	if backup := readTestFile(t, path+".orig"); backup != "Nirvana\nKiss\nNirvana Nirvana" {
+		t.Errorf("backup: got %q", backup)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0o640 {
+		t.Errorf("got mode %v and error %v, expected the mode of the original file", info.Mode(), err)
+	}
+
+	// a symbolic link stays one
+	link := filepath.Join(filepath.Dir(path), "link.txt")
+	if err := os.Symlink("bands.txt", link); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := newEditSearcher(t, "Kiss", "Queen").EditFile(link, EditOption{}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if target, err := os.Readlink(link); err != nil || target != "bands.txt" {
+		t.Errorf("the link was replaced: %q, %v", target, err)
+	}
+	if content := readTestFile(t, path); !strings.Contains(content, "\nQueen\n") {
+		t.Errorf("the target was not edited: %q", content)
+	}
+}
+
+func TestEditFileDryRun(t *testing.T) {
+	searcher := newEditSearcher(t, "Kiss", "Queen")
+	path := writeTestFile(t, "Nirvana\nKiss\n")
+
+	var diff bytes.Buffer
+	selectedCount, err := searcher.EditFile(path, EditOption{IsDryRun: true}, &diff)
+	if err != nil || selectedCount != 1 {
+		t.Fatalf("got %d selected lines and error %v, expected 1", selectedCount, err)
+	}
+	if !strings.Contains(diff.String(), "-Kiss\n+Queen\n") {
+		t.Errorf("got the diff %q", diff.String())
+	}
+	if content := readTestFile(t, path); content != "Nirvana\nKiss\n" {
+		t.Errorf("the file was rewritten: %q", content)
+	}
+}
+
+func TestEditFileMaxCount(t *testing.T) {
+	option := NewOption("a")
+	option.IsReplaced, option.Replacement, option.MaxCount = true, "b", 1
+	searcher, err := New(option)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := writeTestFile(t, "a\na\n")
+
+	if selectedCount, err := searcher.EditFile(path, EditOption{}, nil); err != nil || selectedCount != 1 {
+		t.Fatalf("got %d selected lines and error %v, expected 1", selectedCount, err)
+	}
+	if content := readTestFile(t, path); content != "b\na\n" {
+		t.Errorf("got %q", content)
+	}
+}
+
+// A binary file is left as is, unless it is searched as text
+func TestEditFileBinary(t *testing.T) {
+	searcher := newEditSearcher(t, "Kiss", "Queen")
+	path := writeTestFile(t, "Kiss\x00\n")
+
+	for _, option := range []EditOption{{}, {IsDryRun: true}} {
+		var diff bytes.Buffer
+		if selectedCount, err := searcher.EditFile(path, option, &diff); err != ErrBinaryNotEdited || selectedCount != 0 || diff.Len() > 0 {
+			t.Errorf("dry run %v: got %d selected lines, error %v and the diff %q", option.IsDryRun, selectedCount, err, diff.String())
+		}
+	}
+	if content := readTestFile(t, path); content != "Kiss\x00\n" {
+		t.Errorf("got %q", content)
+	}
+}
+
+func TestEditFileBinaryAsText(t *testing.T) {
+	option := NewOption("Kiss")
+	option.IsReplaced, option.Replacement, option.BinaryFiles = true, "Queen", BinaryFilesText
+	searcher, err := New(option)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := writeTestFile(t, "Kiss\xff\n")
+
+	if selectedCount, err := searcher.EditFile(path, EditOption{}, nil); err != nil || selectedCount != 1 {
+		t.Fatalf("got %d selected lines and error %v, expected 1", selectedCount, err)
+	}
+	if content := readTestFile(t, path); content != "Queen\xff\n" {
+		t.Errorf("got %q", content)
+	}
+}
+
+// A compressed file is left as is even when searched as text
+func TestEditFileCompressed(t *testing.T) {
+	option := NewOption("Kiss")
+	option.IsReplaced, option.Replacement, option.BinaryFiles = true, "Queen", BinaryFilesText
+	searcher, err := New(option)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var compressed bytes.Buffer
+	zw := gzip.NewWriter(&compressed)
+	zw.Write([]byte("Kiss\n"))
+	zw.Close()
+	compressed.WriteString("Kiss\n")
+	path := writeTestFile(t, compressed.String())
+
+	if selectedCount, err := searcher.EditFile(path, EditOption{}, nil); err != ErrArchiveNotEdited || selectedCount != 0 {
+		t.Errorf("got %d selected lines and error %v", selectedCount, err)
+	}
+	if content := readTestFile(t, path); content != compressed.String() {
+		t.Errorf("got %q", content)
+	}
+}
+
+func TestNewPrinterInPlaceArchives(t *testing.T) {
+	option := NewOption("Kiss")
+	option.IsReplaced, option.Replacement = true, "Queen"
+	option.IsZipSearched = true
+	if _, err := NewPrinter(io.Discard, io.Discard, option, PrintOption{InPlace: &EditOption{}}); err == nil {
+		t.Error("expected an error with IsZipSearched")
+	}
+
+	option.IsZipSearched, option.Walk.IsArchiveSearched = false, true
+	if _, err := NewPrinter(io.Discard, io.Discard, option, PrintOption{InPlace: &EditOption{}}); err == nil {
+		t.Error("expected an error with Walk.IsArchiveSearched")
+	}
+}
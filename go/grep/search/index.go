@@ -0,0 +1,120 @@
+package search
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+
+	"github.com/ikraduya/codingchallanges/go/grep/internal/match"
+	"github.com/ikraduya/codingchallanges/go/grep/internal/trigram"
+)
+
+// The Err of the Result of a directory whose index cannot be used with Option.IsIndexed, e.g. it was never
+// built. The directory is still searched, file by file.
+type IndexError struct {
+	Dir string
+	Err error
+}
+
+func (e *IndexError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *IndexError) Unwrap() error {
+	return e.Err
+}
+
+// Load the index of each directory of paths and select the files that may match, a directory without a
+// usable index has a Result with an IndexError. Return no index when the search cannot be narrowed.
+func (searcher *Searcher) loadIndexes(paths []string) (map[string]*trigram.Index, []*Result) {
+	// the compressed files and the archives are not indexed by their content, an approximate match has no
+	// required literal
+	option := searcher.option
+	if !option.IsIndexed || !option.Walk.IsRecurse || option.IsFuzzy || option.IsZipSearched || option.Walk.IsArchiveSearched {
+		return nil, nil
+	}
+	literals, isFolded, isRequired := match.RequiredLiterals(option.Patterns, option.Syntax.regexSyntax(), searcher.expOptions)
+	if !isRequired {
+		return nil, nil
+	}
+
+	indexes := make(map[string]*trigram.Index)
+	results := make([]*Result, 0)
+	for _, dir := range paths {
+		if isFolder, _ := isFolderExists(dir); !isFolder {
+			continue
+		}
+
+		index, err := trigram.Load(dir)
+		if err != nil {
+			results = append(results, &Result{Path: dir, Err: &IndexError{Dir: dir, Err: err}})
+			continue
+		}
+		index.SelectCandidates(literals, isFolded)
+		indexes[dir] = index
+	}
+
+	return indexes, results
+}
+
+// A file that is up to date in the index of its directory and lacks the trigrams of the patterns cannot match
+func isSkippedByIndex(indexes map[string]*trigram.Index, job searchJob) bool {
+	index := indexes[job.root]
+	if index == nil {
+		return false
+	}
+
+	path, err := filepath.Rel(job.root, job.filepath)
+	if err != nil {
+		return false
+	}
+	info, err := os.Stat(job.filepath)
+	if err != nil {
+		return false
+	}
+	return index.IsSkipped(path, info)
+}
+
+// Name of the index file that BuildIndex writes into a directory
+const IndexFilename = trigram.Filename
+
+// Counts of BuildIndex
+type IndexStats struct {
+	Path         string // of the written index
+	FileCount    int    // files in the index
+	ReadCount    int    // files that were new or changed
+	RemovedCount int    // files of the previous index that are gone
+}
+
+// Write the trigram index of the files of dir used by Option.IsIndexed, walked recursively with option.
+// Only the files that are new or changed since the previous build are read again. fn is called with the
+// errors of the walk, and with an IndexError when the previous index cannot be used and is built again.
+func BuildIndex(dir string, option WalkOption, fn func(path string, err error)) (IndexStats, error) {
+	option.IsRecurse = true
+	option.MaxDepth = -1
+	paths := make([]string, 0)
+	Walk([]string{dir}, option, func(path string, err error) error {
+		if err != nil {
+			fn(path, err)
+		} else if relPath, err := filepath.Rel(dir, path); err == nil {
+			paths = append(paths, relPath)
+		}
+		return nil
+	})
+
+	previous, err := trigram.Load(dir)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		fn(dir, &IndexError{Dir: dir, Err: err})
+	}
+	index, stats := trigram.Build(dir, paths, previous)
+	if err := index.Save(); err != nil {
+		return IndexStats{}, err
+	}
+
+	return IndexStats{
+		Path:         filepath.Join(dir, IndexFilename),
+		FileCount:    stats.FileCount,
+		ReadCount:    stats.ReadCount,
+		RemovedCount: stats.RemovedCount,
+	}, nil
+}
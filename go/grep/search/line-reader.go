@@ -0,0 +1,179 @@
+package search
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/ikraduya/codingchallanges/go/grep/internal/match"
+)
+
+// Initial size of the buffer, it grows to hold the longest line of the input up to the maximum line length
+const lineReaderBufferSize = 64 * 1024
+
+// Read the lines of an input from one buffer, the lines that cannot match can be skipped without
+// being copied. A returned line is only valid until the next call.
+type lineReader struct {
+	r          io.Reader
+	buf        []byte
+	start, end int   // unread data
+	err        error // of the last read, the buffered data is still to be returned
+	terminator byte  // '\n', or NUL with -z
+
+	maxLineLength int // -1 for no limit
+}
+
+func newLineReader(r io.Reader, terminator byte, maxLineLength int) *lineReader {
+	return &lineReader{r: r, buf: make([]byte, lineReaderBufferSize), terminator: terminator, maxLineLength: maxLineLength}
+}
+
+// Whether a line of n bytes without its terminator is longer than the maximum, which ends the input
+func (lr *lineReader) isTooLong(n int) bool {
+	if lr.maxLineLength < 0 || n <= lr.maxLineLength {
+		return false
+	}
+
+	lr.err = fmt.Errorf("%w (more than %d bytes)", ErrLineTooLong, lr.maxLineLength)
+	return true
+}
+
+// Offset of the first line of data longer than the maximum, or -1. The lines longer than the buffer are
+// stopped by fill, the shorter ones are only looked for when the maximum is lower.
+func (lr *lineReader) tooLongLineStart(data []byte) int {
+	if lr.maxLineLength < 0 || lr.maxLineLength >= len(lr.buf) {
+		return -1
+	}
+
+	for start := 0; start < len(data); {
+		length := bytes.IndexByte(data[start:], lr.terminator)
+		if length < 0 {
+			length = len(data) - start
+		}
+		if length > lr.maxLineLength {
+			return start
+		}
+		start += length + 1
+	}
+	return -1
+}
+
+// Read more data after the unread one, return false once the input is exhausted
+func (lr *lineReader) fill() bool {
+	if lr.err != nil {
+		return false
+	}
+
+	if lr.start > 0 {
+		copy(lr.buf, lr.buf[lr.start:lr.end])
+		lr.end -= lr.start
+		lr.start = 0
+	}
+	if lr.end == len(lr.buf) {
+		// the buffer only grows for a line that does not fit, the whole lines before it were returned
+		lineLength := lr.end - (bytes.LastIndexByte(lr.buf[:lr.end], lr.terminator) + 1)
+		if lr.isTooLong(lineLength) {
+			return false
+		}
+
+		size := 2 * len(lr.buf)
+		if lr.maxLineLength >= 0 { // enough for one byte past the maximum
+			size = min(size, lr.end-lineLength+lr.maxLineLength+1)
+		}
+		grown := make([]byte, size)
+		copy(grown, lr.buf[:lr.end])
+		lr.buf = grown
+	}
+
+	n, err := lr.r.Read(lr.buf[lr.end:])
+	lr.end += n
+	if err != nil {
+		lr.err = err
+	}
+
+	return true
+}
+
+// Return at least n unread bytes without consuming them, less at the end of the input
+func (lr *lineReader) peek(n int) ([]byte, error) {
+	for lr.end-lr.start < n {
+		if !lr.fill() {
+			break
+		}
+	}
+	if err := lr.readErr(); err != nil {
+		return nil, err
+	}
+
+	if lr.end-lr.start > n {
+		return lr.buf[lr.start : lr.start+n], nil
+	}
+	return lr.buf[lr.start:lr.end], nil
+}
+
+// Return the next line with its terminator, the last line of the input may have none
+func (lr *lineReader) readLine() ([]byte, bool) {
+	searched := 0
+	for {
+		if i := bytes.IndexByte(lr.buf[lr.start+searched:lr.end], lr.terminator); i >= 0 {
+			if lr.isTooLong(searched + i) {
+				return nil, false
+			}
+			line := lr.buf[lr.start : lr.start+searched+i+1]
+			lr.start += len(line)
+			return line, true
+		}
+		searched = lr.end - lr.start
+
+		if !lr.fill() {
+			if lr.err != io.EOF || lr.start == lr.end || lr.isTooLong(lr.end-lr.start) {
+				return nil, false // a read error ends the input, see readErr
+			}
+			line := lr.buf[lr.start:lr.end]
+			lr.start = lr.end
+			return line, true
+		}
+	}
+}
+
+// Skip the lines before the next one where the prefilter has a candidate match, return the number
+// of skipped lines and bytes. The last line of the input is never skipped.
+func (lr *lineReader) skipToCandidate(prefilter *match.Prefilter) (int, int) {
+	skippedLines, skippedBytes := 0, 0
+	skip := func(n int) bool {
+		isStopped := false
+		if i := lr.tooLongLineStart(lr.buf[lr.start : lr.start+n]); i >= 0 {
+			n, isStopped = i, true // the line is left to readLine that reports it
+		}
+		skippedLines += bytes.Count(lr.buf[lr.start:lr.start+n], []byte{lr.terminator})
+		skippedBytes += n
+		lr.start += n
+		return !isStopped
+	}
+
+	searched := 0 // the start of a long line was already searched before the buffer was refilled
+	for {
+		data := lr.buf[lr.start:lr.end]
+		if hit := prefilter.Index(data[searched:]); hit >= 0 {
+			skip(bytes.LastIndexByte(data[:searched+hit], lr.terminator) + 1)
+			return skippedLines, skippedBytes
+		}
+
+		// a match cannot span lines, every complete line of the data is known not to match
+		if !skip(bytes.LastIndexByte(data, lr.terminator) + 1) {
+			return skippedLines, skippedBytes
+		}
+		searched = max(0, lr.end-lr.start-(prefilter.MaxLiteralLen()-1))
+		if !lr.fill() {
+			return skippedLines, skippedBytes
+		}
+	}
+}
+
+// Error that ended the input, nil at the end of the input
+func (lr *lineReader) readErr() error {
+	if lr.err == io.EOF {
+		return nil
+	}
+
+	return lr.err
+}
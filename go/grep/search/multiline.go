@@ -0,0 +1,105 @@
+package search
+
+import (
+	"bytes"
+
+	"github.com/ikraduya/codingchallanges/go/grep/internal/match"
+)
+
+// Matches of a whole input cut at its lines, Contains returns the ranges of the next line and must be
+// called once per line in order, which a line scan does without a prefilter
+type multilineMatches struct {
+	lineRanges [][]match.IndexRange
+	next       int
+}
+
+// Run the regex on the whole content and give every line touched by a match the part of the match in
+// it, the terminator of a line is never part of its ranges. Like in the line scan, a "\r" before a newline
+// is part of the terminator: the regex runs without it, so "$" matches before a "\r\n".
+func newMultilineMatches(content []byte, re *match.Regexp, terminator byte) *multilineMatches {
+	runes := bytes.Runes(content)
+	text, offsets := runes, []int(nil)
+	if terminator == '\n' && bytes.Contains(content, []byte("\r\n")) {
+		text, offsets = withoutCarriageReturns(runes)
+	}
+
+	// the rune index where each line starts, and the end of the content
+	lineStarts := []int{0}
+	for i, r := range runes {
+		if r == rune(terminator) && i+1 < len(runes) {
+			lineStarts = append(lineStarts, i+1)
+		}
+	}
+	lineStarts = append(lineStarts, len(runes))
+
+	lineRanges := make([][]match.IndexRange, len(lineStarts)-1)
+	line := 0
+	for _, indexRange := range re.FindAll(text) {
+		if offsets != nil {
+			indexRange = mapIndexRange(indexRange, offsets)
+		}
+		if indexRange.Start == len(runes) && len(runes) > 0 && runes[len(runes)-1] == rune(terminator) {
+			break // an empty match after the last terminator is not on a line
+		}
+		for line+1 < len(lineRanges) && lineStarts[line+1] <= indexRange.Start {
+			line += 1
+		}
+
+		for ; line < len(lineRanges); line += 1 {
+			lineStart, lineEnd := lineStarts[line], lineStarts[line+1]
+			if lineEnd > lineStart && runes[lineEnd-1] == rune(terminator) {
+				lineEnd -= 1
+				if offsets != nil && lineEnd > lineStart && runes[lineEnd-1] == '\r' {
+					lineEnd -= 1
+				}
+			}
+
+			localRange := match.IndexRange{
+				Start: max(indexRange.Start, lineStart) - lineStart,
+				Stop:  max(min(indexRange.Stop, lineEnd), indexRange.Start, lineStart) - lineStart,
+			}
+			lineRanges[line] = append(lineRanges[line], localRange)
+			if indexRange.Stop <= lineStarts[line+1] {
+				break // the next match may start on the same line
+			}
+		}
+	}
+
+	return &multilineMatches{lineRanges: lineRanges}
+}
+
+// Return runes without the "\r" of each "\r\n", and the index in runes of every rune of the result and
+// of its end
+func withoutCarriageReturns(runes []rune) ([]rune, []int) {
+	text := make([]rune, 0, len(runes))
+	offsets := make([]int, 0, len(runes)+1)
+	for i, r := range runes {
+		if r == '\r' && i+1 < len(runes) && runes[i+1] == '\n' {
+			continue
+		}
+		text = append(text, r)
+		offsets = append(offsets, i)
+	}
+	offsets = append(offsets, len(runes))
+
+	return text, offsets
+}
+
+// Map a range of the text of withoutCarriageReturns to the runes, a "\r" after its last rune stays out
+func mapIndexRange(indexRange match.IndexRange, offsets []int) match.IndexRange {
+	if indexRange.Stop == indexRange.Start {
+		return match.IndexRange{Start: offsets[indexRange.Start], Stop: offsets[indexRange.Start]}
+	}
+	return match.IndexRange{Start: offsets[indexRange.Start], Stop: offsets[indexRange.Stop-1] + 1}
+}
+
+// Satisfy CheckContainsOperation, s and exp are ignored since the matches are already found
+func (matches *multilineMatches) Contains(s []rune, exp []rune, expOptions match.ExpressionOption) []match.IndexRange {
+	if matches.next >= len(matches.lineRanges) {
+		return nil
+	}
+
+	indexRanges := matches.lineRanges[matches.next]
+	matches.next += 1
+	return indexRanges
+}
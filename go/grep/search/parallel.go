@@ -0,0 +1,176 @@
+package search
+
+import (
+	"errors"
+	"iter"
+	"sync"
+
+	"github.com/ikraduya/codingchallanges/go/grep/internal/trigram"
+)
+
+// A file to search, or the error of a path that cannot be searched. index is the walk order.
+type searchJob struct {
+	index    int
+	filepath string
+	root     string // the directory of the paths the file was walked from
+	err      error
+}
+
+// Called by SearchTree in the goroutine that searches the input at path, before searching it. The returned
+// LineFunc receives the lines of the input in that goroutine, nil to only count them, then the returned
+// ResultFunc receives its Result in the goroutine of SearchTree.
+type InputFunc func(path string) (LineFunc, ResultFunc)
+
+// The result of an input and where it is delivered
+type inputResult struct {
+	result *Result
+	fn     ResultFunc
+}
+
+// The results of a job, the members of an archive have one each
+type jobResults struct {
+	index   int
+	results []inputResult
+}
+
+// Search an input with the LineFunc of fn
+func searchInput(path string, fn InputFunc, search func(result *Result, lineFn LineFunc) error) inputResult {
+	lineFn, resultFn := fn(path)
+	result := &Result{Path: path}
+	result.Err = search(result, lineFn)
+	return inputResult{result: result, fn: resultFn}
+}
+
+// A path that was not searched
+func unsearchedInput(path string, err error, fn InputFunc) inputResult {
+	_, resultFn := fn(path)
+	return inputResult{result: &Result{Path: path, Err: err}, fn: resultFn}
+}
+
+func (searcher *Searcher) run(job searchJob, indexes map[string]*trigram.Index, fn InputFunc) []inputResult {
+	if job.err != nil {
+		return []inputResult{unsearchedInput(job.filepath, job.err, fn)}
+	}
+	if isSkippedByIndex(indexes, job) { // searched as an empty input, e.g. a count of 0
+		_, resultFn := fn(job.filepath)
+		return []inputResult{{result: &Result{Path: job.filepath, IsSearched: true}, fn: resultFn}}
+	}
+	if searcher.option.Walk.IsArchiveSearched && archiveKindOf(job.filepath) != archiveNone {
+		return searcher.searchArchiveFile(job.filepath, fn)
+	}
+
+	return []inputResult{searchInput(job.filepath, fn, func(result *Result, lineFn LineFunc) error {
+		return searcher.searchFile(job.filepath, result, lineFn)
+	})}
+}
+
+// Deliver the results of the workers in walk order, or as soon as they are ready when isUnordered
+type resultCollector struct {
+	isUnordered bool
+
+	pending   map[int]jobResults
+	nextIndex int
+}
+
+func (collector *resultCollector) collect(job jobResults) error {
+	if collector.isUnordered {
+		return collector.deliver(job)
+	}
+
+	collector.pending[job.index] = job
+	for {
+		next, isFound := collector.pending[collector.nextIndex]
+		if !isFound {
+			return nil
+		}
+		delete(collector.pending, collector.nextIndex)
+		collector.nextIndex += 1
+		if err := collector.deliver(next); err != nil {
+			return err
+		}
+	}
+}
+
+func (collector *resultCollector) deliver(job jobResults) error {
+	for _, input := range job.results {
+		if input.fn == nil {
+			continue
+		}
+		if err := input.fn(input.result); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Search the files of paths with Option.JobCount workers, fn is called for each input by the worker that
+// searches it. The directories are walked with Option.Walk, a path that cannot be searched is a Result
+// with an Err. Return the error of a ResultFunc.
+func (searcher *Searcher) SearchTree(paths []string, fn InputFunc) error {
+	indexes, indexResults := searcher.loadIndexes(paths)
+	for _, result := range indexResults {
+		if _, resultFn := fn(result.Path); resultFn != nil {
+			if err := resultFn(result); err != nil {
+				return ignoreSkipAll(err)
+			}
+		}
+	}
+
+	jobCount := searcher.option.JobCount
+	jobs := make(chan searchJob, jobCount)
+	results := make(chan jobResults, jobCount)
+	stop := make(chan struct{})
+
+	walker := &fileWalker{option: searcher.option.Walk, stop: stop}
+	go walker.walk(paths, jobs)
+
+	var wg sync.WaitGroup
+	for i := 0; i < jobCount; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				select {
+				case results <- jobResults{index: job.index, results: searcher.run(job, indexes, fn)}:
+				case <-stop:
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	collector := resultCollector{isUnordered: searcher.option.IsUnordered, pending: make(map[int]jobResults)}
+	for job := range results {
+		if err := collector.collect(job); err != nil {
+			close(stop) // the walker and the workers return without sending
+			return ignoreSkipAll(err)
+		}
+	}
+
+	return nil
+}
+
+// Iterate over the results of SearchTree without their lines, breaking out of the loop stops the search
+func (searcher *Searcher) Results(paths []string) iter.Seq[*Result] {
+	return func(yield func(*Result) bool) {
+		searcher.SearchTree(paths, func(path string) (LineFunc, ResultFunc) {
+			return nil, func(result *Result) error {
+				if !yield(result) {
+					return SkipAll
+				}
+				return nil
+			}
+		})
+	}
+}
+
+func ignoreSkipAll(err error) error {
+	if errors.Is(err, SkipAll) {
+		return nil
+	}
+	return err
+}
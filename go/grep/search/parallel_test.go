@@ -0,0 +1,131 @@
+package search
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// A directory of files named in walk order, with a match in the even ones
+func newTestTree(t *testing.T, fileCount int) (string, []string) {
+	t.Helper()
+	root := t.TempDir()
+	paths := make([]string, 0, fileCount)
+	for i := range fileCount {
+		path := filepath.Join(root, fmt.Sprintf("f%02d.txt", i))
+		content := "Kiss\n"
+		if i%2 == 0 {
+			content = "Nirvana\n"
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+
+	return root, paths
+}
+
+func TestSearchTreeOrder(t *testing.T) {
+	root, paths := newTestTree(t, 40)
+	missing := filepath.Join(root, "missing.txt")
+
+	option := NewOption("Nirvana")
+	option.JobCount = 8
+	searcher, err := New(option)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	found := make([]string, 0)
+	selectedCounts := make([]int, 0)
+	var missingErr error
+	for result := range searcher.Results([]string{root, missing}) {
+		found = append(found, result.Path)
+		selectedCounts = append(selectedCounts, result.SelectedCount)
+		if result.Path == missing {
+			missingErr = result.Err
+		}
+	}
+
+	if expected := append(slices.Clone(paths), missing); !slices.Equal(found, expected) {
+		t.Errorf("got %q, expected the walk order %q", found, expected)
+	}
+	for i, count := range selectedCounts[:len(paths)] {
+		if count != 1-i%2 {
+			t.Errorf("%s: %d selected lines", paths[i], count)
+		}
+	}
+	if !errors.Is(missingErr, os.ErrNotExist) {
+		t.Errorf("%s: got error %v", missing, missingErr)
+	}
+}
+
+func TestSearchTreeUnordered(t *testing.T) {
+	root, paths := newTestTree(t, 40)
+
+	option := NewOption("Nirvana")
+	option.JobCount = 8
+	option.IsUnordered = true
+	searcher, err := New(option)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	found := make([]string, 0)
+	for result := range searcher.Results([]string{root}) {
+		found = append(found, result.Path)
+	}
+	slices.Sort(found)
+	if !slices.Equal(found, paths) {
+		t.Errorf("got %q, expected every file once", found)
+	}
+}
+
+// SkipAll of a ResultFunc and a break out of Results stop the search without an error
+func TestSearchTreeSkipAll(t *testing.T) {
+	root, paths := newTestTree(t, 40)
+
+	option := NewOption("Nirvana")
+	option.JobCount = 4
+	searcher, err := New(option)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	delivered := 0
+	err = searcher.SearchTree([]string{root}, func(path string) (LineFunc, ResultFunc) {
+		return nil, func(result *Result) error {
+			delivered += 1
+			if delivered == 3 {
+				return SkipAll
+			}
+			return nil
+		}
+	})
+	if err != nil || delivered != 3 {
+		t.Errorf("SkipAll: got error %v after %d results, expected none after 3", err, delivered)
+	}
+
+	stop := errors.New("stop")
+	err = searcher.SearchTree([]string{root}, func(path string) (LineFunc, ResultFunc) {
+		return nil, func(result *Result) error { return stop }
+	})
+	if err != stop {
+		t.Errorf("got error %v, expected %v", err, stop)
+	}
+
+	found := make([]string, 0)
+	for result := range searcher.Results([]string{root}) {
+		found = append(found, result.Path)
+		if len(found) == 2 {
+			break
+		}
+	}
+	if !slices.Equal(found, paths[:2]) {
+		t.Errorf("break: got %q, expected %q", found, paths[:2])
+	}
+}
@@ -0,0 +1,464 @@
+package search
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/ikraduya/codingchallanges/go/grep/internal/match"
+	"github.com/ikraduya/codingchallanges/go/grep/internal/output"
+)
+
+// What the Printer prints of each input
+type OutputMode int
+
+const (
+	OutputLines             OutputMode = iota // the selected lines and the context lines
+	OutputCount                               // the number of selected lines, like -c
+	OutputFilesWithMatches                    // the path of an input with a selected line, like -l
+	OutputFilesWithoutMatch                   // the path of an input without a selected line, like -L
+	OutputQuiet                               // nothing, only the exit status, like -q
+)
+
+// When the Printer colors the matches, the paths and the separators
+type ColorMode int
+
+const (
+	ColorAuto   ColorMode = iota // when the output is a terminal, unless NO_COLOR is set or the terminal is dumb
+	ColorAlways                  // even into a file or a pipe
+	ColorNever
+)
+
+var colorModes = map[ColorMode]output.ColorMode{
+	ColorAuto:   output.ColorAuto,
+	ColorAlways: output.ColorAlways,
+	ColorNever:  output.ColorNever,
+}
+
+// Parse "auto", "always" or "never", or one of their GNU grep synonyms
+func ParseColorMode(value string) (ColorMode, error) {
+	colorMode, err := output.ParseColorMode(value)
+	if err != nil {
+		return ColorAuto, err
+	}
+	for mode, m := range colorModes {
+		if m == colorMode {
+			return mode, nil
+		}
+	}
+	return ColorAuto, nil
+}
+
+// How a Printer prints the inputs, like the output options of grep
+type PrintOption struct {
+	OutputMode OutputMode
+
+	IsFilepathShown     bool // PrintTree also shows it for several paths or the members of the archives
+	IsLineNumberShown   bool
+	IsByteOffsetShown   bool
+	IsColumnShown       bool
+	IsNullAfterFilepath bool // a NUL instead of the separator after the file name
+
+	IsVimgrep      bool // one row per match, always prefixed with FILE:LINE:COLUMN
+	IsOnlyMatching bool // one row per match with only the matched text
+	IsJSON         bool // one JSON event per line instead of the text output, then a summary
+	ColorMode      ColorMode
+
+	InPlace *EditOption // rewrite the files with the replacements instead of printing their lines when not nil
+
+	ExeName string // prefix of the error messages
+}
+
+// Name of the standard input when a file name is printed
+const StdinName = "(standard input)"
+
+// Print the lines of the searched inputs like grep, the errors are printed as they come and make the exit
+// status of Finish
+type Printer struct {
+	searcher *Searcher // the search of the printed lines
+	editor   *Searcher // the search of Option with PrintOption.InPlace
+	w        io.Writer
+	errW     io.Writer
+	option   PrintOption
+
+	prefix         output.PrefixOption
+	textColorTheme output.TextColorTheme
+	jsonSummary    *output.JSONSummary // one JSON event per line instead of the text output when not nil
+
+	hasPreviousOutput bool
+	isSuccess         bool
+	hasError          bool
+}
+
+// Compile the search of option for printing with printOption to w, the errors are printed to errW. Only
+// the lines that are printed are delivered, e.g. a count stops at the first selected line of each input.
+func NewPrinter(w io.Writer, errW io.Writer, option Option, printOption PrintOption) (*Printer, error) {
+	if printOption.InPlace != nil && (!option.IsReplaced || option.IsInvertExpression) {
+		return nil, errors.New("in-place editing requires Option.IsReplaced and cannot be used with IsInvertExpression")
+	}
+	// the files are rewritten as they are, the matches found in the decompressed content or in the members
+	// would be replaced in the compressed bytes
+	if printOption.InPlace != nil && (option.IsZipSearched || option.Walk.IsArchiveSearched) {
+		return nil, errors.New("in-place editing cannot be used with IsZipSearched or Walk.IsArchiveSearched")
+	}
+	editor, err := New(option)
+	if err != nil {
+		return nil, err
+	}
+
+	// no line is printed, one selected line is enough to know the result unless it is counted
+	searcher := *editor
+	if printOption.OutputMode != OutputLines || printOption.InPlace != nil {
+		searcher.option.IsCountOnly = true
+		if (printOption.OutputMode != OutputCount || printOption.InPlace != nil) && searcher.option.MaxCount != 0 {
+			searcher.option.MaxCount = 1
+		}
+	}
+	// the lines of a binary input are only replaced by a message when they would be printed as text, JSON
+	// carries the invalid UTF-8 in base64
+	if searcher.option.BinaryFiles == BinaryFilesBinary && (searcher.option.IsCountOnly || printOption.IsJSON) {
+		searcher.option.BinaryFiles = BinaryFilesText
+	}
+
+	printer := &Printer{
+		searcher: &searcher,
+		editor:   editor,
+		w:        w,
+		errW:     errW,
+		option:   printOption,
+		prefix: output.PrefixOption{
+			IsFilepathShown:     printOption.IsFilepathShown || printOption.IsVimgrep,
+			IsLineNumberShown:   printOption.IsLineNumberShown || printOption.IsVimgrep,
+			IsByteOffsetShown:   printOption.IsByteOffsetShown,
+			IsColumnShown:       printOption.IsColumnShown || printOption.IsVimgrep,
+			IsNullAfterFilepath: printOption.IsNullAfterFilepath,
+		},
+		textColorTheme: output.NewTextColorTheme(colorModes[printOption.ColorMode]),
+	}
+	if printOption.IsJSON {
+		printer.jsonSummary = output.NewJSONSummary()
+	}
+	return printer, nil
+}
+
+// Whether the selected lines of an input make it count as a success for the exit status, like GNU grep 3.5
+// and later -L does not invert it
+func isSuccess(selectedCount int) bool {
+	return selectedCount > 0
+}
+
+func (printer *Printer) isContextShown() bool {
+	option := printer.searcher.option
+	return option.BeforeContext > 0 || option.AfterContext > 0
+}
+
+// 1-based byte column of the rune at runeIndex
+func byteColumn(line []rune, runeIndex int) int {
+	column := 1
+	for _, r := range line[:runeIndex] {
+		column += utf8.RuneLen(r)
+	}
+
+	return column
+}
+
+// Rune ranges of the submatches of a line, a rune of the line is either a valid rune or one invalid byte
+func indexRangesOf(lineBytes []byte, submatches []Submatch) []match.IndexRange {
+	if submatches == nil {
+		return nil
+	}
+
+	indexRanges := make([]match.IndexRange, len(submatches))
+	runeIndex, byteIndex := 0, 0
+	runeIndexOf := func(target int) int {
+		for byteIndex < target {
+			_, size := utf8.DecodeRune(lineBytes[byteIndex:])
+			byteIndex += size
+			runeIndex++
+		}
+		return runeIndex
+	}
+	for i, submatch := range submatches {
+		indexRanges[i].Start = runeIndexOf(submatch.Start)
+		indexRanges[i].Stop = runeIndexOf(submatch.End)
+	}
+	return indexRanges
+}
+
+// Print the lines of one input as the search delivers them, then what follows them with finish
+type linePrinter struct {
+	printer  *Printer
+	w        io.Writer
+	filepath string
+
+	lastPrintedLineNumber   int
+	isLastPrintedTerminated bool
+	isJSONBegun             bool
+	jsonStats               output.JSONStats
+}
+
+func (printer *Printer) newLinePrinter(w io.Writer, filepath string) *linePrinter {
+	return &linePrinter{printer: printer, w: w, filepath: filepath, isLastPrintedTerminated: true}
+}
+
+// Satisfy LineFunc
+func (lines *linePrinter) printLine(line Line) error {
+	w := lines.w
+	printer := lines.printer
+	printOption := printer.option
+	textColorTheme := printer.textColorTheme
+	lineTerminator := printer.searcher.lineTerminator()
+	isJSON := printer.jsonSummary != nil
+	lineString := string(line.Bytes)
+
+	// print the "--" separator when the line does not follow the last printed one, the separator
+	// between two inputs is printed by printResult
+	if printer.isContextShown() && !isJSON && lines.lastPrintedLineNumber > 0 && line.Number > lines.lastPrintedLineNumber+1 {
+		output.OutputGroupSeparator(w, textColorTheme)
+	}
+	lines.lastPrintedLineNumber = line.Number
+	lines.isLastPrintedTerminated = strings.HasSuffix(lineString, string(lineTerminator))
+
+	// with JSON, the begin event is printed before the first line of the input
+	if isJSON && !lines.isJSONBegun {
+		output.OutputJSONBegin(w, lines.filepath)
+		lines.isJSONBegun = true
+	}
+
+	indexRanges := indexRangesOf(line.Bytes, line.Submatches)
+	linePrefix := output.LinePrefix{
+		Filepath:   lines.filepath,
+		LineNumber: line.Number,
+		ByteOffset: line.ByteOffset,
+		IsContext:  line.IsContext,
+		Option:     printer.prefix,
+	}
+
+	if line.IsContext {
+		switch {
+		case isJSON:
+			output.OutputJSONLine(w, lines.filepath, lineString, line.Number, line.ByteOffset, indexRanges, true)
+		case indexRanges != nil:
+			output.Output(w, []rune(lineString), indexRanges, linePrefix, textColorTheme)
+		default:
+			output.OutputDefaultColor(w, lineString, linePrefix, textColorTheme)
+		}
+		return nil
+	}
+
+	if isJSON { // -o and --vimgrep do not change the events, the matches are in the submatches
+		lines.jsonStats.Matches += output.OutputJSONLine(w, lines.filepath, lineString, line.Number, line.ByteOffset, indexRanges, false)
+		return nil
+	}
+
+	if printer.searcher.option.IsInvertExpression {
+		if !printOption.IsOnlyMatching { // an inverted line has no match to print
+			output.OutputDefaultColor(w, lineString, linePrefix, textColorTheme)
+		}
+		return nil
+	}
+
+	// with a replacement, the replacements are printed and colored, the columns are the ones of the matches
+	runes := []rune(lineString)
+	printedLine, printedRanges := runes, indexRanges
+	if line.Replaced != nil {
+		printedLine, printedRanges = []rune(string(line.Replaced)), indexRangesOf(line.Replaced, line.Replacements)
+		indexRanges = indexRangesOf(line.Bytes, line.ReplacedMatches)
+	}
+
+	if printOption.IsOnlyMatching {
+		lines.isLastPrintedTerminated = true
+		for i, indexRange := range indexRanges {
+			if printedRanges[i].Start == printedRanges[i].Stop {
+				continue
+			}
+			linePrefix.Column = byteColumn(runes, indexRange.Start)
+			linePrefix.ByteOffset = line.ByteOffset + linePrefix.Column - 1 // offset of the match
+			output.OutputOnlyMatching(w, printedLine, printedRanges[i], linePrefix, textColorTheme, lineTerminator)
+		}
+		return nil
+	}
+
+	if printOption.IsVimgrep { // one row per match, each row is terminated even when the line is not
+		isPrintedTerminated := len(printedLine) > 0 && printedLine[len(printedLine)-1] == rune(lineTerminator)
+		lines.isLastPrintedTerminated = true
+		for _, indexRange := range indexRanges {
+			linePrefix.Column = byteColumn(runes, indexRange.Start)
+			output.Output(w, printedLine, printedRanges, linePrefix, textColorTheme)
+			if !isPrintedTerminated {
+				output.OutputExtraLine(w, lineTerminator)
+			}
+		}
+		return nil
+	}
+
+	linePrefix.Column = byteColumn(runes, indexRanges[0].Start)
+	output.Output(w, printedLine, printedRanges, linePrefix, textColorTheme)
+	return nil
+}
+
+// Print what follows the lines of a searched input: the JSON end, the count or the file name
+func (lines *linePrinter) finish(result *Result) {
+	w := lines.w
+	printer := lines.printer
+	textColorTheme := printer.textColorTheme
+
+	if printer.jsonSummary != nil {
+		lines.jsonStats.MatchedLines = result.SelectedCount
+		lines.jsonStats.BytesSearched = result.BytesSearched
+		if lines.isJSONBegun {
+			output.OutputJSONEnd(w, lines.filepath, lines.jsonStats)
+		}
+		printer.jsonSummary.Add(lines.jsonStats)
+	} else if !lines.isLastPrintedTerminated { // last line of the input has no newline
+		output.OutputExtraLine(w, printer.searcher.lineTerminator())
+	}
+
+	switch printer.option.OutputMode {
+	case OutputLines:
+		if result.IsBinary && result.SelectedCount > 0 { // the lines of a binary input are not printed
+			output.OutputBinaryFileMatches(w, lines.filepath)
+		}
+	case OutputCount:
+		output.OutputCount(w, lines.filepath, printer.prefix, result.SelectedCount, textColorTheme)
+	case OutputFilesWithMatches:
+		if result.SelectedCount > 0 {
+			output.OutputFilepath(w, lines.filepath, printer.prefix.IsNullAfterFilepath, textColorTheme)
+		}
+	case OutputFilesWithoutMatch:
+		if result.SelectedCount == 0 {
+			output.OutputFilepath(w, lines.filepath, printer.prefix.IsNullAfterFilepath, textColorTheme)
+		}
+	}
+}
+
+// Print the lines of r as they are searched, name is its file name, e.g. StdinName
+func (printer *Printer) PrintReader(r io.Reader, name string) {
+	lines := printer.newLinePrinter(printer.w, name)
+	result, err := printer.searcher.SearchReader(r, lines.printLine)
+	if result.IsSearched {
+		lines.finish(&result)
+	}
+	if err != nil {
+		fmt.Fprintf(printer.errW, "%s: %s\n", printer.option.ExeName, ErrorText(err))
+		printer.hasError = true
+	}
+	if isSuccess(result.SelectedCount) && (err == nil || result.SelectedCount > 0) {
+		printer.isSuccess = true
+	}
+}
+
+// Print the files of paths file by file, the lines of a file are printed together once it is searched
+func (printer *Printer) PrintTree(paths []string) {
+	if len(paths) > 1 || printer.editor.option.Walk.IsArchiveSearched { // the members of an archive are told apart by their path
+		printer.prefix.IsFilepathShown = true
+	}
+	printer.searcher.SearchTree(paths, printer.printInput)
+}
+
+// Satisfy InputFunc, the lines are printed into a buffer by the goroutine searching the input
+func (printer *Printer) printInput(path string) (LineFunc, ResultFunc) {
+	var buf bytes.Buffer
+	lines := printer.newLinePrinter(&buf, path)
+	return lines.printLine, func(result *Result) error {
+		return printer.printResult(result, lines, &buf)
+	}
+}
+
+// Satisfy ResultFunc, stop the search once the exit status of OutputQuiet is known
+func (printer *Printer) printResult(result *Result, lines *linePrinter, buf *bytes.Buffer) error {
+	exeName := printer.option.ExeName
+	var indexErr *IndexError
+	switch {
+	case errors.As(result.Err, &indexErr): // a warning, the directory is searched file by file
+		if errors.Is(indexErr.Err, os.ErrNotExist) {
+			fmt.Fprintf(printer.errW, "%s: %s: no index, run '%s index build %s', searching every file\n", exeName, indexErr.Dir, exeName, indexErr.Dir)
+		} else {
+			fmt.Fprintf(printer.errW, "%s: %v, searching every file\n", exeName, indexErr.Err)
+		}
+		return nil
+	case errors.Is(result.Err, ErrIsDirectory) || errors.Is(result.Err, ErrNotRegular):
+		fmt.Fprintf(printer.w, "%s: %s: %s\n", exeName, result.Path, ErrorText(result.Err))
+		printer.hasPreviousOutput = false
+		return nil
+	}
+
+	isPrinted := false
+	err := result.Err
+	switch {
+	case !result.IsSearched:
+	case printer.option.InPlace != nil:
+		selectedCount := 0
+		if result.SelectedCount > 0 {
+			selectedCount, err = printer.editor.EditFile(result.Path, *printer.option.InPlace, buf)
+		}
+		switch { // a warning, the file matches like "Binary file X matches"
+		case errors.Is(err, ErrBinaryNotEdited):
+			fmt.Fprintf(printer.errW, "%s: %s: %s (use -a to edit it as text)\n", exeName, result.Path, ErrorText(err))
+			selectedCount, err = result.SelectedCount, nil
+		case errors.Is(err, ErrArchiveNotEdited):
+			fmt.Fprintf(printer.errW, "%s: %s: %s\n", exeName, result.Path, ErrorText(err))
+			selectedCount, err = result.SelectedCount, nil
+		}
+		isPrinted = isSuccess(selectedCount)
+	default:
+		lines.finish(result)
+		isPrinted = isSuccess(result.SelectedCount)
+	}
+
+	// after a read error, the lines read before it are still printed and counted
+	isSearched := err == nil || buf.Len() > 0
+	if buf.Len() > 0 {
+		if printer.isContextShown() && printer.jsonSummary == nil && isSearched && printer.hasPreviousOutput {
+			output.OutputGroupSeparator(printer.w, printer.textColorTheme)
+		}
+		printer.w.Write(buf.Bytes())
+		printer.hasPreviousOutput = isSearched
+	}
+	if err != nil {
+		fmt.Fprintf(printer.errW, "%s: %s: %s\n", exeName, result.Path, ErrorText(err))
+		printer.hasError = true
+	}
+
+	if isSearched && isPrinted {
+		printer.isSuccess = true
+		if printer.option.OutputMode == OutputQuiet {
+			return SkipAll // the exit status is known, skip the remaining files
+		}
+	}
+	return nil
+}
+
+// Return the text of err like grep prints it after a path, with a capital first letter and without the
+// operation and the path of an *os.PathError, e.g. "No such file or directory"
+func ErrorText(err error) string {
+	if pathErr, ok := err.(*os.PathError); ok {
+		err = pathErr.Err
+	}
+
+	text := err.Error()
+	r, size := utf8.DecodeRuneInString(text)
+	return string(unicode.ToUpper(r)) + text[size:]
+}
+
+// Print the JSON summary after the inputs and return the exit status of grep: 0 when a line was selected,
+// 1 when none was and 2 after an error, even when lines were selected unless with OutputQuiet
+func (printer *Printer) Finish() int {
+	if printer.jsonSummary != nil {
+		output.OutputJSONSummary(printer.w, printer.jsonSummary)
+	}
+
+	switch {
+	case printer.isSuccess && (!printer.hasError || printer.option.OutputMode == OutputQuiet):
+		return 0
+	case printer.hasError:
+		return 2
+	default:
+		return 1
+	}
+}
@@ -0,0 +1,41 @@
+package search
+
+import (
+	"bytes"
+	"unicode/utf8"
+)
+
+// Replace the matches of a line with Option.Replacement, the bytes outside of the matches are copied as is
+// so that invalid UTF-8 is kept. Return the replaced line, the ranges of the replacements in it and the
+// replaced matches, or a nil line when it has no match.
+func (searcher *Searcher) replaceLine(line []byte) ([]byte, []Submatch, []Submatch) {
+	runes := bytes.Runes(line)
+	replaced, replacedRanges, matchedRanges := searcher.replacer.Replace(runes)
+	if len(matchedRanges) == 0 {
+		return nil, nil, nil
+	}
+
+	// a rune of the line is either a valid rune or one invalid byte
+	runeOffsets := make([]int, 0, len(runes)+1)
+	for i := 0; i < len(line); {
+		runeOffsets = append(runeOffsets, i)
+		_, size := utf8.DecodeRune(line[i:])
+		i += size
+	}
+	runeOffsets = append(runeOffsets, len(line))
+
+	edited := make([]byte, 0, len(line))
+	replacements := make([]Submatch, len(matchedRanges))
+	submatches := make([]Submatch, len(matchedRanges))
+	last := 0
+	for i, matchedRange := range matchedRanges {
+		submatches[i] = Submatch{Start: runeOffsets[matchedRange.Start], End: runeOffsets[matchedRange.Stop]}
+		edited = append(edited, line[last:submatches[i].Start]...)
+		replacements[i].Start = len(edited)
+		edited = append(edited, string(replaced[replacedRanges[i].Start:replacedRanges[i].Stop])...)
+		replacements[i].End = len(edited)
+		last = submatches[i].End
+	}
+
+	return append(edited, line[last:]...), replacements, submatches
+}
@@ -0,0 +1,210 @@
+package search
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ikraduya/codingchallanges/go/grep/internal/match"
+)
+
+// Byte offsets of the rune ranges of a line, a rune of the line is either a valid rune or one invalid byte
+func submatchesOf(lineBytes []byte, indexRanges []match.IndexRange) []Submatch {
+	if indexRanges == nil {
+		return nil
+	}
+
+	submatches := make([]Submatch, len(indexRanges))
+	runeIndex, byteIndex := 0, 0
+	offsetOf := func(target int) int {
+		for runeIndex < target {
+			_, size := utf8.DecodeRune(lineBytes[byteIndex:])
+			byteIndex += size
+			runeIndex++
+		}
+		return byteIndex
+	}
+	for i, indexRange := range indexRanges { // the ranges are sorted and do not overlap
+		submatches[i].Start = offsetOf(indexRange.Start)
+		submatches[i].End = offsetOf(indexRange.Stop)
+	}
+	return submatches
+}
+
+func (searcher *Searcher) searchReader(r io.Reader, result *Result, fn LineFunc) (err error) {
+	defer match.CatchBacktrackLimit(&err)
+
+	option := searcher.option
+	if fn == nil { // the lines are only counted
+		fn = func(line Line) error { return nil }
+	}
+	if option.IsZipSearched {
+		decompressed, err := newDecompressingReader(r)
+		if err != nil {
+			return err
+		}
+		r = decompressed
+	}
+
+	checkContainsFunc, prefilter := searcher.checkContainsFunc, searcher.prefilter
+	if searcher.multilineRegexp != nil { // the whole input is matched before its lines are delivered
+		content, err := io.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		r = bytes.NewReader(content)
+		checkContainsFunc = newMultilineMatches(content, searcher.multilineRegexp, searcher.lineTerminator()).Contains
+		prefilter = nil
+	}
+
+	reader := newLineReader(r, searcher.lineTerminator(), option.MaxLineLength)
+	scan := lineScan{searcher: searcher, checkContainsFunc: checkContainsFunc, prefilter: prefilter, fn: fn,
+		isCountOnly: option.IsCountOnly, maxCount: option.MaxCount, beforeContext: option.BeforeContext, afterContext: option.AfterContext}
+	if option.BinaryFiles != BinaryFilesText {
+		block, err := reader.peek(binaryDetectionSize)
+		if err != nil {
+			return err
+		}
+
+		if isBinaryBlock(block, searcher.lineTerminator()) {
+			result.IsBinary = true
+			switch option.BinaryFiles {
+			case BinaryFilesWithoutMatch:
+				reader = newLineReader(strings.NewReader(""), searcher.lineTerminator(), option.MaxLineLength) // searched as an empty input
+			case BinaryFilesBinary: // one selected line is enough to know that it matches
+				scan.isCountOnly = true
+				if scan.maxCount != 0 {
+					scan.maxCount = 1
+				}
+			}
+		}
+	}
+
+	result.IsSearched = true
+	err = scan.run(reader, result)
+	if err != nil {
+		return err
+	}
+
+	// the lines read before the error are already delivered
+	return reader.readErr()
+}
+
+// The search of the lines of one input
+type lineScan struct {
+	searcher          *Searcher
+	checkContainsFunc match.CheckContainsOperation
+	prefilter         *match.Prefilter
+	fn                LineFunc
+
+	isCountOnly   bool
+	maxCount      int
+	beforeContext int
+	afterContext  int
+}
+
+func (scan *lineScan) run(reader *lineReader, result *Result) error {
+	expOptions := scan.searcher.expOptions
+	if scan.isCountOnly {
+		scan.beforeContext, scan.afterContext = 0, 0
+	}
+	matchOptions := expOptions
+	matchOptions.IsMatchOnly = scan.isCountOnly // the lines are not delivered, where they match is not needed
+
+	beforeLines := newContextRingBuffer(scan.beforeContext)
+	afterRemaining := 0
+	selectedCount := 0
+	isMaxCountReached := func() bool {
+		return scan.maxCount >= 0 && selectedCount >= scan.maxCount
+	}
+
+	lineNumber := 0
+	byteOffset := 0
+	defer func() {
+		result.SelectedCount = selectedCount
+		result.BytesSearched = byteOffset
+	}()
+	for !(isMaxCountReached() && afterRemaining == 0) {
+		// the lines that do not match are not delivered and can be skipped at once
+		if scan.prefilter != nil && !expOptions.IsInvertExpression && scan.beforeContext == 0 && afterRemaining == 0 {
+			skippedLines, skippedBytes := reader.skipToCandidate(scan.prefilter)
+			lineNumber += skippedLines
+			byteOffset += skippedBytes
+		}
+
+		lineBytes, isRead := reader.readLine()
+		if !isRead {
+			break
+		}
+
+		lineNumber += 1
+		lineOffset := byteOffset
+		byteOffset += len(lineBytes)
+
+		// only the delivered lines need their matches, an exact prefilter decides the others without
+		// decoding them into runes
+		var indexRanges []match.IndexRange
+		isMatched := false
+		switch {
+		case scan.prefilter != nil && scan.prefilter.Index(lineBytes) < 0:
+		case scan.prefilter != nil && scan.prefilter.IsExact() && scan.isCountOnly:
+			isMatched = true
+		default:
+			indexRanges = scan.checkContainsFunc(bytes.Runes(lineBytes), nil, matchOptions) // the expressions are compiled in checkContainsFunc
+			isMatched = indexRanges != nil
+		}
+		line := func(isContext bool) Line {
+			delivered := Line{Bytes: lineBytes, Number: lineNumber, ByteOffset: lineOffset, IsContext: isContext, Submatches: submatchesOf(lineBytes, indexRanges)}
+			// an inverted selected line has no match to replace
+			if !isContext && !expOptions.IsInvertExpression && scan.searcher.replacer != nil {
+				if replaced, replacements, replacedMatches := scan.searcher.replaceLine(lineBytes); replaced != nil {
+					delivered.Replaced, delivered.Replacements, delivered.ReplacedMatches = replaced, replacements, replacedMatches
+				}
+			}
+			return delivered
+		}
+
+		isSelected := isMatched != expOptions.IsInvertExpression
+		if isMaxCountReached() { // only the trailing context is left
+			afterRemaining -= 1
+			if err := scan.fn(line(true)); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if isSelected && scan.isCountOnly {
+			selectedCount += 1
+			continue
+		}
+
+		if !isSelected {
+			if afterRemaining > 0 {
+				afterRemaining -= 1
+				if err := scan.fn(line(true)); err != nil {
+					return err
+				}
+			} else if scan.beforeContext > 0 {
+				contextLine := line(true)
+				contextLine.Bytes = bytes.Clone(lineBytes) // the buffer of the reader is reused
+				beforeLines.push(contextLine)
+			}
+			continue
+		}
+
+		for _, beforeLine := range beforeLines.drain() {
+			if err := scan.fn(beforeLine); err != nil {
+				return err
+			}
+		}
+		afterRemaining = scan.afterContext
+		selectedCount += 1
+
+		if err := scan.fn(line(false)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,270 @@
+package search
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/rand"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// Search input and return its result with the delivered lines like grep -n prints them: "2:a" for a
+// selected line, "3-b" for a context line
+func searchLines(t *testing.T, option Option, input string) (Result, []string) {
+	t.Helper()
+	searcher, err := New(option)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := make([]string, 0)
+	result, err := searcher.SearchReader(strings.NewReader(input), func(line Line) error {
+		separator := ":"
+		if line.IsContext {
+			separator = "-"
+		}
+		lines = append(lines, fmt.Sprintf("%d%s%s", line.Number, separator, strings.TrimSuffix(string(line.Bytes), "\n")))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result, lines
+}
+
+func TestSearchReader(t *testing.T) {
+	input := "Nirvana\nKiss\nQueen\nNirvana Bush\nBlur\nOasis\nNirvana\n"
+	cases := []struct {
+		name          string
+		change        func(option *Option)
+		expected      []string
+		selectedCount int
+	}{
+		{"selected", func(option *Option) {}, []string{"1:Nirvana", "4:Nirvana Bush", "7:Nirvana"}, 3},
+		{"after context", func(option *Option) { option.AfterContext = 1 },
+			[]string{"1:Nirvana", "2-Kiss", "4:Nirvana Bush", "5-Blur", "7:Nirvana"}, 3},
+		{"before context", func(option *Option) { option.BeforeContext = 2 },
+			[]string{"1:Nirvana", "2-Kiss", "3-Queen", "4:Nirvana Bush", "5-Blur", "6-Oasis", "7:Nirvana"}, 3},
+		{"max count", func(option *Option) { option.MaxCount = 2 }, []string{"1:Nirvana", "4:Nirvana Bush"}, 2},
+		{"max count and context", func(option *Option) { option.MaxCount = 1; option.AfterContext = 2 },
+			[]string{"1:Nirvana", "2-Kiss", "3-Queen"}, 1},
+		{"max count 0", func(option *Option) { option.MaxCount = 0 }, []string{}, 0},
+		{"inverted", func(option *Option) { option.IsInvertExpression = true },
+			[]string{"2:Kiss", "3:Queen", "5:Blur", "6:Oasis"}, 4},
+		{"inverted context", func(option *Option) { option.IsInvertExpression = true; option.MaxCount = 1; option.AfterContext = 1 },
+			[]string{"2:Kiss", "3-Queen"}, 1},
+		{"count only", func(option *Option) { option.IsCountOnly = true }, []string{}, 3},
+		{"line match", func(option *Option) { option.IsLineMatch = true }, []string{"1:Nirvana", "7:Nirvana"}, 2},
+	}
+
+	for _, c := range cases {
+		option := NewOption("Nirvana")
+		c.change(&option)
+		result, lines := searchLines(t, option, input)
+		if !slices.Equal(lines, c.expected) || result.SelectedCount != c.selectedCount {
+			t.Errorf("%s: got %q (%d selected), expected %q (%d selected)", c.name, lines, result.SelectedCount, c.expected, c.selectedCount)
+		}
+	}
+}
+
+// The submatches are byte offsets of the line, an inverted selected line has none
+func TestSearchReaderSubmatches(t *testing.T) {
+	option := NewOption("é+")
+	option.Syntax = SyntaxExtended
+	searcher, err := New(option)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var submatches []Submatch
+	if _, err := searcher.SearchReader(strings.NewReader("café éé\n"), func(line Line) error {
+		submatches = line.Submatches
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if expected := []Submatch{{3, 5}, {6, 10}}; !slices.Equal(submatches, expected) {
+		t.Errorf("got %v, expected %v", submatches, expected)
+	}
+}
+
+// A "\r" before a newline is part of the terminator, with and without IsMultiline
+func TestSearchReaderCRLF(t *testing.T) {
+	input := "a\r\nb\r\nc a\n"
+	cases := []struct {
+		pattern     string
+		isMultiline bool
+		expected    []string
+	}{
+		{"a$", false, []string{"1:[{0 1}]", "3:[{2 3}]"}},
+		{"a$", true, []string{"1:[{0 1}]", "3:[{2 3}]"}},
+		{"^b$", false, []string{"2:[{0 1}]"}},
+		{"^b$", true, []string{"2:[{0 1}]"}},
+		{`a$\n^b`, true, []string{"1:[{0 1}]", "2:[{0 1}]"}},
+		{`a\r$`, false, []string{}},
+		{`a\r$`, true, []string{}},
+	}
+
+	for _, c := range cases {
+		option := NewOption(c.pattern)
+		option.Syntax = SyntaxPerl
+		option.IsMultiline = c.isMultiline
+		searcher, err := New(option)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		lines := make([]string, 0)
+		if _, err := searcher.SearchReader(strings.NewReader(input), func(line Line) error {
+			lines = append(lines, fmt.Sprintf("%d:%v", line.Number, line.Submatches))
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(lines, c.expected) {
+			t.Errorf("%q (multiline %v): got %q, expected %q", c.pattern, c.isMultiline, lines, c.expected)
+		}
+	}
+}
+
+func TestSearchReaderBinary(t *testing.T) {
+	input := "Nirvana\x00\nKiss\nNirvana\n"
+	cases := []struct {
+		mode          BinaryFilesMode
+		expected      []string
+		selectedCount int
+	}{
+		{BinaryFilesBinary, []string{}, 1}, // one selected line is enough
+		{BinaryFilesWithoutMatch, []string{}, 0},
+		{BinaryFilesText, []string{"1:Nirvana\x00", "3:Nirvana"}, 2}, // not even detected
+	}
+
+	for _, c := range cases {
+		option := NewOption("Nirvana")
+		option.BinaryFiles = c.mode
+		result, lines := searchLines(t, option, input)
+		if result.IsBinary != (c.mode != BinaryFilesText) || !slices.Equal(lines, c.expected) || result.SelectedCount != c.selectedCount {
+			t.Errorf("mode %d: got %q (%d selected, binary %v), expected %q (%d selected)",
+				c.mode, lines, result.SelectedCount, result.IsBinary, c.expected, c.selectedCount)
+		}
+	}
+
+	option := NewOption("Nirvana")
+	option.IsNullData = true // a NUL ends a line
+	if result, lines := searchLines(t, option, "Nirvana\x00Kiss\x00"); result.IsBinary || !slices.Equal(lines, []string{"1:Nirvana\x00"}) {
+		t.Errorf("-z: got %q (binary %v)", lines, result.IsBinary)
+	}
+}
+
+// An error of the LineFunc stops the search of the input
+func TestSearchReaderLineFuncError(t *testing.T) {
+	searcher, err := New(NewOption("a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stop := errors.New("stop")
+	delivered := 0
+	_, err = searcher.SearchReader(strings.NewReader("a\na\na\n"), func(line Line) error {
+		delivered += 1
+		return stop
+	})
+	if err != stop || delivered != 1 {
+		t.Errorf("got error %v after %d lines, expected %v after 1 line", err, delivered, stop)
+	}
+}
+
+// Lines of words where the patterns are rare, like most of the lines of a source tree
+var benchmarkInput = sync.OnceValue(func() []byte {
+	rng := rand.New(rand.NewSource(1))
+	words := strings.Fields("the quick brown fox jumps over lazy dog grep pattern search line match return func error nil")
+	rare := []string{"Nirvana", "Kiss", "Springsteen"}
+
+	var buf bytes.Buffer
+	for buf.Len() < 8*1024*1024 {
+		for n := 4 + rng.Intn(12); n > 0; n-- {
+			if rng.Intn(2000) == 0 {
+				buf.WriteString(rare[rng.Intn(len(rare))])
+			} else {
+				buf.WriteString(words[rng.Intn(len(words))])
+			}
+			buf.WriteByte(' ')
+		}
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes()
+})
+
+// Compare the search of the raw bytes with the prefilter against the decoding of every line into runes
+func BenchmarkPrefilter(b *testing.B) {
+	cases := []struct {
+		name   string
+		option Option
+	}{
+		{"literal", NewOption("Nirvana")},
+		{"alternatives", NewOption("Nirvana", "Kiss")},
+		{"spaced", NewOption("return nil")},
+		{"regex", NewOption("func.*error Springsteen")},
+		{"lines", NewOption("Springsteen")},
+	}
+
+	input := benchmarkInput()
+	for _, c := range cases {
+		c.option.IsCountOnly = c.name != "lines" // like -c, "lines" delivers its lines like -n
+		searcher, err := New(c.option)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if searcher.prefilter == nil {
+			b.Fatalf("%s: no prefilter", c.name)
+		}
+
+		for _, path := range []string{"bytes", "runes"} {
+			b.Run(c.name+"/"+path, func(b *testing.B) {
+				searcher := *searcher
+				if path == "runes" {
+					searcher.prefilter = nil
+				}
+				b.SetBytes(int64(len(input)))
+				for b.Loop() {
+					if _, err := searcher.SearchReader(bytes.NewReader(input), func(line Line) error { return nil }); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
+
+// A long list of expressions that are not literals, like "-f hosts" where "." matches any rune, so that
+// every line goes through the regex
+func BenchmarkExpressionList(b *testing.B) {
+	patterns := make([]string, 2000)
+	for i := range patterns {
+		patterns[i] = fmt.Sprintf("host%d.example.com", i)
+	}
+
+	var buf bytes.Buffer
+	for i := 0; i < 20_000; i++ {
+		fmt.Fprintf(&buf, "connected to host%d.example.com on port 22\n", i%5000) // 40% of the lines match
+	}
+	input := buf.Bytes()
+
+	for _, mode := range []string{"lines", "count"} {
+		b.Run(mode, func(b *testing.B) {
+			option := NewOption(patterns...)
+			option.IsCountOnly = mode == "count" // like -c, "lines" delivers its lines like -n
+			searcher, err := New(option)
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.SetBytes(int64(len(input)))
+			for b.Loop() {
+				if _, err := searcher.SearchReader(bytes.NewReader(input), func(line Line) error { return nil }); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,309 @@
+// Package search is the search engine of ccgrep: a Searcher matches patterns against the lines of a reader,
+// a file or the files of directory trees and delivers the selected lines with their matches.
+//
+//	searcher, err := search.New(search.NewOption("Nirvana"))
+//	if err != nil {
+//		return err
+//	}
+//	_, err = searcher.SearchReader(os.Stdin, func(line search.Line) error {
+//		_, err := fmt.Printf("%d:%s", line.Number, line.Bytes)
+//		return err
+//	})
+//
+// SearchTree delivers the lines from the goroutines searching the files, so the functions of each input
+// must not share state without synchronization. A Printer prints the results of the inputs in order like
+// grep:
+//
+//	printer, err := search.NewPrinter(os.Stdout, os.Stderr, search.NewOption("Nirvana"), search.PrintOption{})
+//	if err != nil {
+//		return err
+//	}
+//	printer.PrintTree([]string{"."})
+//	os.Exit(printer.Finish())
+package search
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"runtime"
+
+	"github.com/ikraduya/codingchallanges/go/grep/internal/match"
+	"github.com/ikraduya/codingchallanges/go/grep/internal/normalize"
+)
+
+// Syntax of the patterns
+type Syntax int
+
+const (
+	SyntaxBasic    Syntax = iota // POSIX basic regular expressions, like -G
+	SyntaxExtended               // POSIX extended regular expressions, like -E
+	SyntaxPerl                   // Perl compatible regular expressions, like -P
+	SyntaxFixed                  // every rune is a literal
+)
+
+func (syntax Syntax) regexSyntax() match.RegexSyntax {
+	switch syntax {
+	case SyntaxExtended:
+		return match.SyntaxExtended
+	case SyntaxPerl:
+		return match.SyntaxPerl
+	case SyntaxFixed:
+		return match.SyntaxFixed
+	}
+	return match.SyntaxBasic
+}
+
+// Unicode normalization form of the matching
+type Normalization int
+
+const (
+	NormalizationNone Normalization = iota // the patterns and the lines are matched as they are
+	NormalizationNFC
+	NormalizationNFD
+	NormalizationNFKC
+	NormalizationNFKD
+)
+
+var normalizationForms = map[Normalization]normalize.Form{
+	NormalizationNone: normalize.FormNone,
+	NormalizationNFC:  normalize.FormNFC,
+	NormalizationNFD:  normalize.FormNFD,
+	NormalizationNFKC: normalize.FormNFKC,
+	NormalizationNFKD: normalize.FormNFKD,
+}
+
+// Parse "nfc", "nfd", "nfkc" or "nfkd"
+func ParseNormalization(name string) (Normalization, error) {
+	form, err := normalize.ParseForm(name)
+	if err != nil {
+		return NormalizationNone, err
+	}
+	for normalization, f := range normalizationForms {
+		if f == form {
+			return normalization, nil
+		}
+	}
+	return NormalizationNone, nil
+}
+
+// How the case of the letters of the patterns and the lines is matched
+type CaseMode int
+
+const (
+	CaseSensitive   CaseMode = iota // the runes match only themselves
+	CaseInsensitive                 // with the full Unicode case folding, e.g. 'ß' matches "SS"
+	SmartCase                       // a pattern without an upper case letter is case insensitive
+)
+
+// How the lines are selected and which ones are delivered
+type Option struct {
+	Patterns []string // a line is selected when one of them matches
+	Syntax   Syntax
+	CaseMode CaseMode
+
+	IsInvertExpression bool // select the lines that do not match
+	IsWordMatch        bool // match only whole words
+	IsLineMatch        bool // match only whole lines
+	Normalization      Normalization
+
+	IsFuzzy       bool // match the patterns as fixed strings within FuzzyDistance edits
+	FuzzyDistance int
+
+	IsMultiline       bool // match the patterns against the whole input, the lines touched by a match are selected
+	IsMultilineDotAll bool // '.' also matches a newline with IsMultiline
+
+	// deliver the selected lines with their matches replaced by the Replacement template, "$1" or "${1}" is a
+	// group, "${name}" a named group and "$$" a dollar sign. Not with IsFuzzy or IsMultiline.
+	IsReplaced  bool
+	Replacement string
+
+	// bytes of the longest line held in memory, a longer line ends the search of its input with
+	// ErrLineTooLong, 0 for DefaultMaxLineLength and -1 for no limit. IsMultiline reads the whole input.
+	MaxLineLength int
+
+	BeforeContext int  // context lines delivered before each selected line
+	AfterContext  int  // context lines delivered after each selected line
+	MaxCount      int  // stop reading an input after NUM selected lines, -1 for no limit
+	IsCountOnly   bool // only count the selected lines, no line is delivered
+	IsNullData    bool // the lines are terminated by NUL instead of a newline
+
+	BinaryFiles   BinaryFilesMode
+	IsZipSearched bool // decompress the gzip, bzip2 and zlib compressed inputs before searching them
+
+	Walk        WalkOption // which files of the paths SearchTree searches
+	IsIndexed   bool       // skip the files that the trigram index of a directory rules out
+	JobCount    int        // files searched in parallel by SearchTree
+	IsUnordered bool       // deliver the results of SearchTree as soon as they are ready instead of in walk order
+}
+
+// Longest line of the default option, like the largest file of the trigram index. The lines are not streamed
+// in pieces: the expressions, the context and the output need a whole line, so the limit bounds the memory
+// a line can take, and -1 lifts it.
+const DefaultMaxLineLength = 256 << 20
+
+// Err of an input with a line longer than Option.MaxLineLength, the lines before it were delivered
+var ErrLineTooLong = errors.New("line too long")
+
+// Err of an input where a pattern with back references or lookarounds gave up on a line, like the
+// backtracking limit of PCRE
+var ErrBacktrackLimit = match.ErrBacktrackLimit
+
+// Return the default option of the patterns: no limit, recursive walks and a job per CPU
+func NewOption(patterns ...string) Option {
+	return Option{
+		Patterns:      patterns,
+		MaxLineLength: DefaultMaxLineLength,
+		MaxCount:      -1,
+		Walk:          WalkOption{IsRecurse: true, MaxDepth: -1, MaxArchiveDepth: 2},
+		JobCount:      runtime.GOMAXPROCS(0),
+	}
+}
+
+// A match in a line, Start and End are byte offsets in Line.Bytes
+type Submatch struct {
+	Start int
+	End   int
+}
+
+// A selected or a context line of an input
+type Line struct {
+	Bytes      []byte // with its terminator, the last line of an input may have none
+	Number     int    // 1-based
+	ByteOffset int    // of the start of the line in the input
+	IsContext  bool
+
+	// the matches of the line, e.g. the matches of a context line with IsInvertExpression, nil for an
+	// inverted selected line
+	Submatches []Submatch
+
+	// with Option.IsReplaced, the selected line with its matches replaced, the replaced matches and the
+	// ranges of their replacements in Replaced. nil for a context line or when no match is replaced.
+	Replaced        []byte
+	ReplacedMatches []Submatch
+	Replacements    []Submatch
+}
+
+// Called with the lines of an input in order. The Bytes and Replaced are only valid until it returns, an
+// error stops the search of the input.
+type LineFunc func(line Line) error
+
+// Outcome of the search of one input, its lines were delivered to a LineFunc
+type Result struct {
+	Path          string
+	SelectedCount int
+	BytesSearched int
+	IsBinary      bool // the input was detected as binary, see BinaryFilesMode
+	IsSearched    bool // false when the input could not be read at all
+	Err           error
+}
+
+// Called by SearchTree with the result of each input, SkipAll stops the search without an error
+type ResultFunc func(result *Result) error
+
+// Returned by a ResultFunc to stop SearchTree without an error
+var SkipAll = fs.SkipAll
+
+// The compiled patterns of an Option, a Searcher can search several inputs at the same time
+type Searcher struct {
+	option            Option
+	expOptions        match.ExpressionOption
+	checkContainsFunc match.CheckContainsOperation
+	prefilter         *match.Prefilter
+	multilineRegexp   *match.Regexp   // matched against the whole input instead of line by line when not nil
+	replacer          *match.Replacer // with IsReplaced
+}
+
+// Compile the patterns of option
+func New(option Option) (*Searcher, error) {
+	if option.IsReplaced && (option.IsFuzzy || option.IsMultiline) {
+		return nil, errors.New("a replacement cannot be used with IsFuzzy or IsMultiline")
+	}
+	if option.JobCount < 1 {
+		option.JobCount = runtime.GOMAXPROCS(0)
+	}
+	if option.MaxLineLength == 0 {
+		option.MaxLineLength = DefaultMaxLineLength
+	}
+
+	isDotAll := option.IsMultiline && option.IsMultilineDotAll
+	if option.IsNullData && !option.IsMultiline && option.Syntax != SyntaxPerl {
+		isDotAll = true // like GNU grep, "." matches a newline inside a NUL-terminated line
+	}
+
+	searcher := &Searcher{
+		option: option,
+		expOptions: match.ExpressionOption{
+			IsInvertExpression: option.IsInvertExpression,
+			IsCaseInsensitive:  option.CaseMode == CaseInsensitive,
+			IsSmartCase:        option.CaseMode == SmartCase,
+			IsWordMatch:        option.IsWordMatch,
+			IsLineMatch:        option.IsLineMatch,
+			IsDotAll:           isDotAll,
+			Normalization:      normalizationForms[option.Normalization],
+		},
+	}
+
+	var err error
+	syntax := option.Syntax.regexSyntax()
+	if option.IsFuzzy { // an approximate match has no required literal
+		searcher.checkContainsFunc, err = match.CompileFuzzyPatterns(option.Patterns, option.FuzzyDistance, searcher.expOptions)
+	} else {
+		searcher.checkContainsFunc, err = match.CompilePatterns(option.Patterns, syntax, searcher.expOptions)
+		searcher.prefilter = match.NewPrefilter(option.Patterns, syntax, searcher.expOptions)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if option.IsMultiline && len(option.Patterns) > 0 {
+		multilineOptions := searcher.expOptions
+		multilineOptions.IsMultiline = true
+		multilineOptions.IsNullData = option.IsNullData
+		if searcher.multilineRegexp, err = match.CompileRegexList(option.Patterns, syntax, multilineOptions); err != nil {
+			return nil, err
+		}
+	}
+
+	if option.IsReplaced {
+		replaceOptions := searcher.expOptions
+		replaceOptions.IsInvertExpression = false
+		if searcher.replacer, err = match.CompileReplacer(option.Patterns, syntax, replaceOptions, option.Replacement); err != nil {
+			return nil, err
+		}
+	}
+	return searcher, nil
+}
+
+func (searcher *Searcher) lineTerminator() byte {
+	if searcher.option.IsNullData {
+		return 0
+	}
+	return '\n'
+}
+
+// Search the lines of r, fn is called with the selected and the context lines. After a read error, the
+// lines read before it were delivered and are counted.
+func (searcher *Searcher) SearchReader(r io.Reader, fn LineFunc) (Result, error) {
+	var result Result
+	err := searcher.searchReader(r, &result, fn)
+	return result, err
+}
+
+// Search the lines of the file at path like SearchReader, Result.Path is path
+func (searcher *Searcher) SearchFile(path string, fn LineFunc) (Result, error) {
+	result := Result{Path: path}
+	err := searcher.searchFile(path, &result, fn)
+	return result, err
+}
+
+func (searcher *Searcher) searchFile(path string, result *Result, fn LineFunc) error {
+	fp, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer fp.Close()
+
+	return searcher.searchReader(fp, result, fn)
+}
@@ -0,0 +1,277 @@
+package search
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ikraduya/codingchallanges/go/grep/internal/ignore"
+	"github.com/ikraduya/codingchallanges/go/grep/internal/trigram"
+)
+
+// Which files of the paths are searched
+type WalkOption struct {
+	IsRecurse bool
+
+	Includes    []string // globs of the file names to search, every file when empty
+	Excludes    []string // globs of the file names to skip
+	ExcludeDirs []string // globs of the directory names to skip when recursing
+	MaxDepth    int      // deepest level below a directory of the paths, -1 for no limit
+
+	IsHiddenShown    bool // search the files and directories starting with a dot
+	IsIgnoreDisabled bool // do not read .gitignore, .ignore and the git excludes
+
+	IsArchiveSearched bool // search the members of the zip and tar archives, the globs apply to them
+	MaxArchiveDepth   int  // deepest nesting level of an archive that is opened, 1 for the archives of the walk
+}
+
+// Names of the ignore files of a directory, from the lowest to the highest precedence
+const (
+	gitignoreFilename = ".gitignore"
+	ignoreFilename    = ".ignore"
+)
+
+// The errors of the paths that are neither searched nor walked
+var (
+	ErrIsDirectory = errors.New("is a directory") // without WalkOption.IsRecurse
+	ErrNotRegular  = errors.New("is not a regular file or directory")
+)
+
+type fileWalker struct {
+	option WalkOption
+
+	index     int
+	jobs      chan<- searchJob
+	stop      <-chan struct{} // closed when the jobs are no longer read
+	isStopped bool
+}
+
+func isGlobMatched(globs []string, name string) bool {
+	for _, glob := range globs {
+		if isMatched, _ := filepath.Match(glob, name); isMatched {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (option WalkOption) isFileIncluded(name string) bool {
+	if len(option.Includes) > 0 && !isGlobMatched(option.Includes, name) {
+		return false
+	}
+
+	return !isGlobMatched(option.Excludes, name)
+}
+
+// An archive is only left out by --exclude, --include selects its members
+func (walker *fileWalker) isFileIncluded(name string) bool {
+	if walker.option.IsArchiveSearched && archiveKindOf(name) != archiveNone {
+		return !isGlobMatched(walker.option.Excludes, name)
+	}
+
+	return walker.option.isFileIncluded(name)
+}
+
+func (walker *fileWalker) send(job searchJob) {
+	job.index = walker.index
+	walker.index += 1
+	select {
+	case walker.jobs <- job:
+	case <-walker.stop:
+		walker.isStopped = true
+	}
+}
+
+// Send a job per file of the paths in walk order, directories are walked with IsRecurse
+func (walker *fileWalker) walk(filepaths []string, jobs chan<- searchJob) {
+	defer close(jobs)
+	walker.jobs = jobs
+
+	for _, file := range filepaths {
+		if walker.isStopped {
+			return
+		}
+
+		if isFileExistsAndRegular(file) {
+			if walker.isFileIncluded(filepath.Base(file)) {
+				walker.send(searchJob{filepath: file})
+			}
+			continue
+		}
+
+		isFolder, err := isFolderExists(file)
+		if !isFolder {
+			if err == nil { // actually exists but not a regular folder
+				err = ErrNotRegular
+			}
+			walker.send(searchJob{filepath: file, err: err})
+			continue
+		} // is a folder
+
+		if !walker.option.IsRecurse {
+			walker.send(searchJob{filepath: file, err: ErrIsDirectory})
+			continue
+		}
+
+		if err := walker.walkDir(file); err != nil {
+			walker.send(searchJob{filepath: file, err: err})
+		}
+	}
+}
+
+func (walker *fileWalker) walkDir(root string) error {
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return err
+	}
+	repoRoot, isInRepository := ignore.FindRepositoryRoot(absRoot)
+
+	// loaded when entering a directory, the matcher of a path is the one of its parent directory
+	matchers := make(map[string]*ignore.Matcher)
+	parentMatcher := walker.parentMatcher(absRoot, repoRoot, isInRepository)
+
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if walker.isStopped {
+			return fs.SkipAll
+		}
+		if err != nil { // the walk goes on without the unreadable directory
+			walker.send(searchJob{filepath: path, err: err})
+			return nil
+		}
+
+		relPath, _ := filepath.Rel(root, path)
+		absPath := filepath.Join(absRoot, relPath)
+		depth := 0
+		if relPath != "." {
+			depth = strings.Count(relPath, string(filepath.Separator)) + 1
+			parentMatcher = matchers[filepath.Dir(filepath.Clean(path))]
+		}
+
+		if depth > 0 && walker.isSkipped(d, absPath, parentMatcher) {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+
+		if d.IsDir() {
+			if walker.option.MaxDepth >= 0 && depth >= walker.option.MaxDepth {
+				return fs.SkipDir
+			}
+			matchers[filepath.Clean(path)] = walker.dirMatcher(parentMatcher, absPath, isInRepository)
+			return nil
+		}
+
+		if isFileExistsAndRegular(path) && walker.isFileIncluded(d.Name()) {
+			walker.send(searchJob{filepath: path, root: root})
+		}
+
+		return nil
+	})
+}
+
+// Whether an entry below a directory of the paths is left out of the walk
+func (walker *fileWalker) isSkipped(d fs.DirEntry, absPath string, matcher *ignore.Matcher) bool {
+	name := d.Name()
+	if !walker.option.IsHiddenShown && strings.HasPrefix(name, ".") {
+		return true
+	}
+	if !d.IsDir() && name == trigram.Filename {
+		return true
+	}
+	if d.IsDir() && (isGlobMatched(walker.option.ExcludeDirs, name) || (name == ".git" && !walker.option.IsIgnoreDisabled)) {
+		return true
+	}
+
+	return matcher != nil && matcher.IsIgnored(absPath, d.IsDir())
+}
+
+// Matcher of the ignore files above a directory of the paths, up to the root of its repository
+func (walker *fileWalker) parentMatcher(absRoot string, repoRoot string, isInRepository bool) *ignore.Matcher {
+	if walker.option.IsIgnoreDisabled {
+		return nil
+	}
+
+	matcher := &ignore.Matcher{}
+	if !isInRepository {
+		return matcher
+	}
+
+	matcher = matcher.With(ignore.LoadRepositoryPatternLists(repoRoot)...)
+	parents := make([]string, 0)
+	if absRoot != repoRoot { // the repository root is an ancestor of absRoot
+		for dir := filepath.Dir(absRoot); ; dir = filepath.Dir(dir) {
+			parents = append(parents, dir)
+			if dir == repoRoot {
+				break
+			}
+		}
+	}
+	for i := len(parents) - 1; i >= 0; i-- {
+		matcher = walker.dirMatcher(matcher, parents[i], isInRepository)
+	}
+
+	return matcher
+}
+
+// Add the ignore files of a directory, .gitignore only counts in a git repository
+func (walker *fileWalker) dirMatcher(parentMatcher *ignore.Matcher, absDir string, isInRepository bool) *ignore.Matcher {
+	if parentMatcher == nil {
+		return nil
+	}
+
+	filenames := []string{ignoreFilename}
+	if isInRepository {
+		filenames = []string{gitignoreFilename, ignoreFilename}
+	}
+
+	lists := make([]*ignore.PatternList, 0, len(filenames))
+	for _, filename := range filenames {
+		list, err := ignore.LoadPatternList(filepath.Join(absDir, filename), absDir)
+		if err != nil {
+			walker.send(searchJob{filepath: filepath.Join(absDir, filename), err: err})
+			continue
+		}
+		lists = append(lists, list)
+	}
+
+	return parentMatcher.With(lists...)
+}
+
+// Call fn with each file of paths that a search reads, in walk order, or with the error of a path that
+// cannot be searched. An error of fn stops the walk and is returned, except SkipAll.
+func Walk(paths []string, option WalkOption, fn func(path string, err error) error) error {
+	jobs := make(chan searchJob)
+	stop := make(chan struct{})
+	walker := &fileWalker{option: option, stop: stop}
+	go walker.walk(paths, jobs)
+
+	for job := range jobs {
+		if err := fn(job.filepath, job.err); err != nil {
+			close(stop)
+			return ignoreSkipAll(err)
+		}
+	}
+	return nil
+}
+
+func isFileExistsAndRegular(filepath string) bool {
+	info, err := os.Stat(filepath)
+	if err == nil {
+		return info.Mode().IsRegular()
+	}
+
+	return false
+}
+
+func isFolderExists(filepath string) (bool, error) {
+	info, err := os.Stat(filepath)
+	if err == nil {
+		return info.Mode().IsDir(), nil
+	}
+
+	return false, err
+}
@@ -33,3 +33,225 @@ echo ""
 
 ./ccgrep -i A rockbands.txt | wc -l
 echo ""
+
+./ccgrep "^Iron Maiden$" rockbands.txt
+echo ""
+
+./ccgrep "B.*h" rockbands.txt
+echo ""
+
+./ccgrep -E "^[KV][a-z]+$" rockbands.txt
+echo ""
+
+./ccgrep -E "\d{4}|(Ninteen|Nineteen) [[:alpha:]]+" test-subdir/BFS1985.txt
+echo ""
+
+./ccgrep "^[KV][a-z]\+$" rockbands.txt
+echo ""
+
+./ccgrep -F "A.D." rockbands.txt
+echo ""
+
+./ccgrep -P "(?<=\s)\d+?(?=,)" test-subdir/BFS1985.txt
+echo ""
+
+./ccgrep -e Kiss -e "^Van" rockbands.txt
+echo ""
+
+./ccgrep -f patterns.txt rockbands.txt
+echo ""
+
+./ccgrep -i -F -f patterns.txt -e SABBATH rockbands.txt
+echo ""
+
+./ccgrep -n -b --column Madonna test-subdir/BFS1985.txt
+echo ""
+
+./ccgrep --vimgrep "19" test-subdir/BFS1985.txt
+echo ""
+
+printf 'Nirvana and Nirvana' | ./ccgrep --vimgrep Nirvana
+echo ""
+
+./ccgrep -n -C 1 -e Kiss -e Motorhead -e Whitesnake rockbands.txt
+echo ""
+
+cat test-subdir/BFS1985.txt | ./ccgrep -A 1 -B 2 Nirvana
+echo ""
+
+printf 'Kiss\nBush\nNirvana\n' | ./ccgrep -B 100000000 Nirvana
+echo ""
+
+./ccgrep -c a rockbands.txt symbols.txt
+echo ""
+
+./ccgrep -l Nirvana rockbands.txt symbols.txt test-subdir/BFS1985.txt
+echo ""
+
+./ccgrep -L Nirvana rockbands.txt symbols.txt test-subdir/BFS1985.txt
+echo ""
+
+./ccgrep -q Nirvana rockbands.txt && echo "found"
+echo ""
+
+./ccgrep -m 2 -n an rockbands.txt
+echo ""
+
+./ccgrep -o -n "[0-9]\+" test-subdir/BFS1985.txt
+echo ""
+
+./ccgrep -w Kiss rockbands.txt
+echo ""
+
+./ccgrep -x -F Kiss rockbands.txt
+echo ""
+
+echo "foo food foo_ x.foo" | ./ccgrep -o -w -F -e foo -e food
+echo ""
+
+./ccgrep --color=always -n Nirvana rockbands.txt
+echo ""
+
+GREP_COLORS="ms=04;32:fn=34:se=" ./ccgrep --color=always -C 1 Nirvana rockbands.txt symbols.txt
+echo ""
+
+./ccgrep --color=never -n Nirvana rockbands.txt
+echo ""
+
+./ccgrep -r -j 4 -n -C 1 Nirvana rockbands.txt test-subdir symbols.txt
+echo ""
+
+./ccgrep -r -j 1 -c Nirvana rockbands.txt test-subdir symbols.txt
+echo ""
+
+./ccgrep -r -l Nirvana .
+echo ""
+
+./ccgrep -r -l --include "*.txt" --exclude "rock*" Nirvana .
+echo ""
+
+./ccgrep -r -l --max-depth 1 --exclude-dir test-subdir Nirvana .
+echo ""
+
+printf 'Nirvana\0\nNirvana\n' | ./ccgrep Nirvana
+echo ""
+
+printf 'Nirvana\0\nNirvana\n' | ./ccgrep -a -c Nirvana
+echo ""
+
+printf 'Nirvana\0\nNirvana\n' | ./ccgrep -I Nirvana || echo "skipped"
+echo ""
+
+{ head -c 2000000 /dev/zero | tr '\0' a; echo " Nirvana"; } | ./ccgrep -c Nirvana
+echo ""
+
+./ccgrep Kiss rockbands.txt nonexistent.txt; echo "exit status: $?"
+echo ""
+
+./ccgrep --json -C 1 Nirvana rockbands.txt | grep -v '"type":"summary"'
+echo ""
+
+printf 'caf\xe9 Nirvana\n' | ./ccgrep --json Nirvana | grep -v '"type":"summary"'
+echo ""
+
+./ccgrep -E --replace '$2 $1' '(Bang) (Tango)' rockbands.txt
+echo ""
+
+./ccgrep --dry-run --replace 'Nevermind' Nirvana rockbands.txt
+echo ""
+
+./ccgrep --dry-run --replace 'Band' -x -e 'AC/DC' -e Kiss -e Motorhead rockbands.txt
+echo ""
+
+edited=$(mktemp -d)
+cp rockbands.txt "$edited/bands.txt"
+ln -s bands.txt "$edited/link.txt"
+./ccgrep --in-place=.orig --replace 'Nevermind' Nirvana "$edited/link.txt" | sed "s|$edited/||"
+ls "$edited"
+readlink "$edited/link.txt"
+./ccgrep -c Nevermind "$edited/bands.txt" | sed "s|$edited/||"
+rm -r "$edited"
+echo ""
+
+printf 'func foo() {\n\treturn 1\n}\n' | ./ccgrep -U -n -E 'foo\(\) \{\n\treturn'
+echo ""
+
+printf 'begin\nmiddle\nend\n' | ./ccgrep -U --multiline-dotall -c 'begin.*end'
+echo ""
+
+printf 'Nirvana\nBush\0Oasis\0' | ./ccgrep -z Nirvana | tr '\0' '@'
+echo ""
+
+printf 'Nirvana\nBush\0Bush\nx\0' | ./ccgrep -z -c -e '^Bush$' -e '^x'
+echo ""
+
+printf 'Nirvana\nBush\0Oasis\0' | ./ccgrep -z -x 'Nirvana.Bush' | tr '\0' '@'
+echo ""
+
+./ccgrep -l -Z Nirvana rockbands.txt | tr '\0' '@'
+echo ""
+
+gzip -c rockbands.txt | ./ccgrep --search-zip -n Nirvana
+echo ""
+
+archives=$(mktemp -d)
+tar cf "$archives/bands.tar" rockbands.txt
+./ccgrep -r -n --search-archives Nirvana "$archives" | sed "s|$archives/||"
+rm -r "$archives"
+echo ""
+
+archives=$(mktemp -d)
+echo "this is not a gzip stream" > "$archives/broken.tgz"
+cp rockbands.txt "$archives"
+tar cf "$archives/bands.tar" -C "$archives" broken.tgz rockbands.txt
+./ccgrep -c --search-archives Nirvana "$archives/bands.tar" 2>&1 | sed "s|$archives/||"
+rm -r "$archives"
+echo ""
+
+./ccgrep --fuzzy 1 -n Nirvanna rockbands.txt
+echo ""
+
+./ccgrep --fuzzy 1 -i -o -w 'iron maidn' rockbands.txt
+echo ""
+
+printf 'baaa bba\n' | ./ccgrep --fuzzy 1 -o -w abba
+echo ""
+
+printf 'Straße\nSTRASSE\nΟΔΥΣΣΕΥΣ\nὀδυσσεύς\n' | ./ccgrep -i -o -e 'strasse' -e 'ὈΔΥΣΣΕΎΣ'
+echo ""
+
+printf 'caf\xc3\xa9\ncafe\xcc\x81\n' | ./ccgrep -c --normalize=nfc 'café'
+echo ""
+
+printf '\xef\xac\x81sh\n' | ./ccgrep --normalize=nfkc -i -o 'FISH'
+echo ""
+
+./ccgrep -S -c nirvana rockbands.txt
+echo ""
+
+./ccgrep -S -n -E -e 'NIRVANA' -e 'iron\W+maiden' rockbands.txt
+echo ""
+
+indexed=$(mktemp -d)
+cp rockbands.txt test.txt "$indexed"
+./ccgrep index build "$indexed" | sed "s|$indexed/||"
+echo "Nirvana, written after the build" > "$indexed/new.txt"
+./ccgrep -r -l --index Nirvana "$indexed" | sed "s|$indexed/||"
+./ccgrep index build "$indexed" | sed "s|$indexed/||"
+rm -r "$indexed"
+echo ""
+
+echo b | ./ccgrep '\(a*\)*\1'
+echo ""
+
+head -c 5000000 /dev/zero | tr '\0' a | ./ccgrep -c -P '(a*)*\d'
+echo ""
+
+printf 'f(x)\n' | ./ccgrep -o -E 'x)'
+echo ""
+
+./ccgrep -L Nirvana symbols.txt; echo "exit status: $?"
+echo ""
+
+printf 'short\n%0300d\nafter\n' 0 | ./ccgrep -n --max-line-length 200 -e short -e after; echo "exit status: $?"
+echo ""
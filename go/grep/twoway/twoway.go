@@ -0,0 +1,127 @@
+package twoway
+
+import "github.com/ikraduya/codingchallanges/go/grep/comparisonutils"
+
+// Two-Way search of one pattern (Crochemore and Perrin). The pattern is split at a critical
+// factorization, the right part is compared left to right then the left part right to left, which
+// gives a linear time search in constant space whatever the alphabet.
+type Searcher struct {
+	pattern        []rune
+	comparisonFunc comparisonutils.RuneComparisonFunc
+
+	critical   int  // last index of the left part, -1 when it is empty
+	period     int  // period of the pattern when isPeriodic, otherwise the shift after a match
+	isPeriodic bool // the left part is a suffix of the period
+}
+
+// Return the start of the maximal suffix of the folded pattern minus one, and its period. With
+// isReversed, the suffix is maximal for the reversed order.
+func maximalSuffix(folded []rune, isReversed bool) (int, int) {
+	ms, j, k, p := -1, 0, 1, 1
+	for j+k < len(folded) {
+		a, b := folded[j+k], folded[ms+k]
+		if isReversed {
+			a, b = b, a
+		}
+
+		switch {
+		case a < b:
+			j += k
+			k = 1
+			p = j - ms
+		case a == b:
+			if k != p {
+				k += 1
+			} else {
+				j += p
+				k = 1
+			}
+		default:
+			ms = j
+			j = ms + 1
+			k, p = 1, 1
+		}
+	}
+
+	return ms, p
+}
+
+// The factorization orders the runes of the pattern, the fold function makes the order consistent with
+// the comparison function, e.g. 'A' and 'a' are the same rune for a case insensitive comparison
+func NewSearcher(pattern []rune, comparisonFunc comparisonutils.RuneComparisonFunc, foldFunc comparisonutils.RuneFoldFunc) *Searcher {
+	folded := make([]rune, len(pattern))
+	for i, r := range pattern {
+		folded[i] = foldFunc(r)
+	}
+
+	searcher := &Searcher{pattern: pattern, comparisonFunc: comparisonFunc}
+	if len(pattern) == 0 {
+		return searcher
+	}
+
+	critical, period := maximalSuffix(folded, false)
+	reversedCritical, reversedPeriod := maximalSuffix(folded, true)
+	if reversedCritical > critical {
+		critical, period = reversedCritical, reversedPeriod
+	}
+	searcher.critical = critical
+
+	// the pattern is periodic when its left part occurs again one period later
+	searcher.isPeriodic = critical+1+period <= len(pattern)
+	for i := 0; searcher.isPeriodic && i <= critical; i++ {
+		searcher.isPeriodic = folded[i] == folded[i+period]
+	}
+
+	if searcher.isPeriodic {
+		searcher.period = period
+	} else {
+		searcher.period = max(critical+1, len(pattern)-critical-1) + 1
+	}
+
+	return searcher
+}
+
+// Return the index of the first occurrence of the pattern in s at or after start, or -1
+func (searcher *Searcher) Index(s []rune, start int) int {
+	patLen := len(searcher.pattern)
+	if patLen == 0 {
+		return start
+	}
+
+	pattern, equals := searcher.pattern, searcher.comparisonFunc
+	critical, period := searcher.critical, searcher.period
+
+	memory := -1 // prefix of the window already known to match, only for a periodic pattern
+	for j := start; j <= len(s)-patLen; {
+		// right part, left to right
+		i := max(critical, memory) + 1
+		for i < patLen && equals(s[i+j], pattern[i]) {
+			i += 1
+		}
+		if i < patLen {
+			j += i - critical
+			memory = -1
+			continue
+		}
+
+		// left part, right to left
+		lowest := -1
+		if searcher.isPeriodic {
+			lowest = memory
+		}
+		i = critical
+		for i > lowest && equals(s[i+j], pattern[i]) {
+			i -= 1
+		}
+		if i <= lowest {
+			return j
+		}
+
+		j += period
+		if searcher.isPeriodic {
+			memory = patLen - period - 1
+		}
+	}
+
+	return -1
+}